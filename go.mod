@@ -1,5 +1,5 @@
 module github.com/verifalia/verifalia-go-sdk
 
-go 1.18
+go 1.23
 
 require github.com/ericlagergren/decimal v0.0.0-20221120152707-495c53812d05
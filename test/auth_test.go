@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/credit"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+)
+
+// fakeJwt builds an unsigned JWT with the specified claims, which is enough for the client (the signature is
+// validated by the Verifalia API).
+func fakeJwt(claims map[string]interface{}) string {
+	encode := func(value interface{}) string {
+		data, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	return encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims) + ".signature"
+}
+
+// fakeTokenServer is a fake Verifalia API which issues bearer tokens and serves the credits balance to the requests
+// which carry the last issued token.
+type fakeTokenServer struct {
+	*recordingServer
+
+	// The claims of the issued tokens, in addition to a unique identifier; if mfa is true, the first token issued
+	// for the credentials requires the verification of the "123456" TOTP code.
+	claims map[string]interface{}
+	mfa    bool
+
+	// The number of requests authenticated by a valid token to reject with the HTTP status code 401.
+	rejections int32
+
+	// The number of token requests to throttle with the HTTP status code 429.
+	throttlings int32
+
+	issued    int32
+	lastToken atomic.Value
+}
+
+func newFakeTokenServer(t *testing.T, claims map[string]interface{}, mfa bool) *fakeTokenServer {
+	server := &fakeTokenServer{claims: claims, mfa: mfa}
+	server.lastToken.Store("")
+
+	server.recordingServer = newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/auth/tokens":
+			if atomic.AddInt32(&server.throttlings, -1) >= 0 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			if body != `{"username":"username","password":"password"}` {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if server.mfa {
+				server.writeToken(w, map[string]interface{}{"verifalia:mfa": true}, false)
+				return
+			}
+
+			server.writeToken(w, server.claims, true)
+
+		case r.Method == http.MethodPost && r.URL.Path == "/auth/totp/verifications":
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") || body != `{"passCode":"123456"}` {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			server.writeToken(w, server.claims, true)
+
+		case r.URL.Path == "/email-validations":
+			// The user lacks the permission to list the jobs
+
+			w.WriteHeader(http.StatusForbidden)
+
+		case r.URL.Path == "/credits/balance":
+			if r.Header.Get("Authorization") != "Bearer "+server.lastToken.Load().(string) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if atomic.AddInt32(&server.rejections, -1) >= 0 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			_, _ = fmt.Fprint(w, `{"creditPacks": 100.5, "freeCredits": 25, "freeCreditsResetIn": "05:00:00"}`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	return server
+}
+
+func (server *fakeTokenServer) writeToken(w http.ResponseWriter, claims map[string]interface{}, final bool) {
+	tokenClaims := map[string]interface{}{"jti": atomic.AddInt32(&server.issued, 1)}
+
+	for name, value := range claims {
+		tokenClaims[name] = value
+	}
+
+	token := fakeJwt(tokenClaims)
+
+	if final {
+		server.lastToken.Store(token)
+	}
+
+	_, _ = fmt.Fprintf(w, `{"accessToken": %q}`, token)
+}
+
+func (server *fakeTokenServer) tokenRequests(path string) int {
+	count := 0
+
+	for _, request := range server.Requests() {
+		if request.Path == path {
+			count++
+		}
+	}
+
+	return count
+}
+
+func buildBearerCreditClient(server *fakeTokenServer, totpTokenProvider auth.TotpTokenProvider) credit.Client {
+	provider := auth.NewBearerAuthProvider("username", "password", []string{server.URL}, totpTokenProvider)
+
+	return credit.Client{RestClient: rest.NewMultiplexedRestClient(provider, "test", []string{server.URL})}
+}
+
+func TestBearerAuthentication(t *testing.T) {
+	getBalances := func(t *testing.T, credits credit.Client, count int) {
+		for i := 0; i < count; i++ {
+			if _, err := credits.GetBalance(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("caching", func(t *testing.T) {
+		server := newFakeTokenServer(t, map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}, false)
+		getBalances(t, buildBearerCreditClient(server, nil), 5)
+
+		if tokens := server.tokenRequests("/auth/tokens"); tokens != 1 {
+			t.Fatalf("unexpected number of token requests: %v", tokens)
+		}
+	})
+
+	t.Run("no expiration", func(t *testing.T) {
+		server := newFakeTokenServer(t, nil, false)
+		getBalances(t, buildBearerCreditClient(server, nil), 5)
+
+		if tokens := server.tokenRequests("/auth/tokens"); tokens != 1 {
+			t.Fatalf("unexpected number of token requests: %v", tokens)
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		// Tokens about to expire are refreshed before being used
+
+		server := newFakeTokenServer(t, map[string]interface{}{
+			"iat": time.Now().Add(-time.Hour).Unix(),
+			"exp": time.Now().Add(30 * time.Second).Unix(),
+		}, false)
+		getBalances(t, buildBearerCreditClient(server, nil), 3)
+
+		if tokens := server.tokenRequests("/auth/tokens"); tokens != 3 {
+			t.Fatalf("unexpected number of token requests: %v", tokens)
+		}
+	})
+
+	t.Run("short-lived", func(t *testing.T) {
+		// The refresh margin of tokens which live less than a minute is capped to a fraction of their lifetime
+
+		for name, claims := range map[string]map[string]interface{}{
+			"with issue time":    {"iat": time.Now().Unix(), "exp": time.Now().Add(40 * time.Second).Unix()},
+			"without issue time": {"exp": time.Now().Add(40 * time.Second).Unix()},
+		} {
+			t.Run(name, func(t *testing.T) {
+				server := newFakeTokenServer(t, claims, false)
+				getBalances(t, buildBearerCreditClient(server, nil), 3)
+
+				if tokens := server.tokenRequests("/auth/tokens"); tokens != 1 {
+					t.Fatalf("unexpected number of token requests: %v", tokens)
+				}
+			})
+		}
+	})
+
+	t.Run("mfa", func(t *testing.T) {
+		server := newFakeTokenServer(t, nil, true)
+		passCodes := []string{"000000", "123456"}
+		var prompts int
+
+		getBalances(t, buildBearerCreditClient(server, func(ctx context.Context) (string, error) {
+			prompts++
+			return passCodes[prompts-1], nil
+		}), 3)
+
+		if prompts != 2 || server.tokenRequests("/auth/tokens") != 1 || server.tokenRequests("/auth/totp/verifications") != 2 {
+			t.Fatalf("unexpected multi-factor authentication: %v prompts, %+v", prompts, server.Requests())
+		}
+	})
+
+	t.Run("mfa without totp provider", func(t *testing.T) {
+		server := newFakeTokenServer(t, nil, true)
+		credits := buildBearerCreditClient(server, nil)
+
+		if _, err := credits.GetBalance(); !errors.Is(err, verifalia.ErrAuthenticationFailed) {
+			t.Fatalf("unexpected error without a TotpTokenProvider: %v", err)
+		}
+
+		if tokens := server.tokenRequests("/auth/tokens"); tokens != 1 {
+			t.Fatalf("unexpected number of token requests: %v", tokens)
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		// A rejected token is discarded and a new one is acquired for the retried request
+
+		server := newFakeTokenServer(t, nil, false)
+		server.rejections = 1
+
+		getBalances(t, buildBearerCreditClient(server, nil), 2)
+
+		if tokens := server.tokenRequests("/auth/tokens"); tokens != 2 {
+			t.Fatalf("unexpected number of token requests: %v", tokens)
+		}
+	})
+}
+
+func TestBearerAuthenticationForbidden(t *testing.T) {
+	server := newFakeTokenServer(t, nil, false)
+	provider := auth.NewBearerAuthProvider("username", "password", []string{server.URL}, nil)
+	restClient := rest.NewMultiplexedRestClient(provider, "test", []string{server.URL})
+
+	// Requests rejected because of missing permissions do not discard the token
+
+	for i := 0; i < 3; i++ {
+		response, err := restClient.Invoke(rest.InvocationOptions{
+			Method:   http.MethodGet,
+			Resource: "email-validations",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := rest.NewAPIError(response); !errors.Is(err, verifalia.ErrAuthorizationFailed) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if tokens := server.tokenRequests("/auth/tokens"); tokens != 1 {
+		t.Fatalf("unexpected number of token requests: %v", tokens)
+	}
+}
+
+func TestBearerAuthenticationWaitersObserveContext(t *testing.T) {
+	server := newFakeTokenServer(t, nil, true)
+
+	prompted := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+
+	credits := buildBearerCreditClient(server, func(ctx context.Context) (string, error) {
+		once.Do(func() { close(prompted) })
+		<-release
+		return "123456", nil
+	})
+
+	// The first request acquires the token and keeps waiting for the TOTP code
+
+	leaderErr := make(chan error, 1)
+
+	go func() {
+		_, err := credits.GetBalance()
+		leaderErr <- err
+	}()
+
+	<-prompted
+
+	// The other requests wait for the same token, but only as long as their contexts allow
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	startedOn := time.Now()
+
+	if _, err := credits.GetBalanceWithContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error while waiting for the token: %v", err)
+	}
+
+	if elapsed := time.Since(startedOn); elapsed > 2*time.Second {
+		t.Fatalf("the request waited for the token beyond its context: %v", elapsed)
+	}
+
+	close(release)
+
+	if err := <-leaderErr; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := credits.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens := server.tokenRequests("/auth/tokens"); tokens != 1 {
+		t.Fatalf("unexpected number of token requests: %v", tokens)
+	}
+}
+
+func TestBearerAuthenticationPanickingTotpProvider(t *testing.T) {
+	server := newFakeTokenServer(t, nil, true)
+	var prompts int32
+
+	credits := buildBearerCreditClient(server, func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&prompts, 1) == 1 {
+			panic("can't read the TOTP code")
+		}
+
+		return "123456", nil
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic of the TotpTokenProvider has not been propagated")
+			}
+		}()
+
+		_, _ = credits.GetBalance()
+	}()
+
+	// The panic does not leave the acquisition of the token pending: the next request acquires a new token
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := credits.GetBalanceWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if prompts := atomic.LoadInt32(&prompts); prompts != 2 {
+		t.Fatalf("unexpected number of TOTP prompts: %v", prompts)
+	}
+}
+
+func TestBearerAuthenticationThrottled(t *testing.T) {
+	// Throttled token requests are reported as such, rather than as rejected credentials
+
+	server := newFakeTokenServer(t, nil, false)
+	server.throttlings = 1
+
+	credits := buildBearerCreditClient(server, nil)
+	_, err := credits.GetBalance()
+
+	if !errors.Is(err, verifalia.ErrRateLimited) || errors.Is(err, verifalia.ErrAuthenticationFailed) {
+		t.Fatalf("unexpected error for a throttled token request: %v", err)
+	}
+
+	// The retry policy applies to them, honouring the Retry-After header
+
+	server = newFakeTokenServer(t, nil, false)
+	server.throttlings = 1
+
+	provider := auth.NewBearerAuthProvider("username", "password", []string{server.URL}, nil)
+	credits = credit.Client{RestClient: rest.NewMultiplexedRestClient(provider, "test", []string{server.URL, server.URL})}
+	startedOn := time.Now()
+
+	if _, err := credits.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(startedOn); elapsed < time.Second || server.tokenRequests("/auth/tokens") != 2 {
+		t.Fatalf("unexpected retry of the throttled token request: %v, %+v", elapsed, server.Requests())
+	}
+}
+
+func TestBearerAuthenticationFailure(t *testing.T) {
+	server := newFakeTokenServer(t, nil, false)
+	provider := auth.NewBearerAuthProvider("username", "wrong-password", []string{server.URL}, nil)
+	credits := credit.Client{RestClient: rest.NewMultiplexedRestClient(provider, "test", []string{server.URL, server.URL, server.URL})}
+
+	_, err := credits.GetBalance()
+
+	if !errors.Is(err, verifalia.ErrAuthenticationFailed) || errors.Is(err, verifalia.ErrAllEndpointsUnreachable) {
+		t.Fatalf("unexpected error for rejected credentials: %v", err)
+	}
+
+	// Rejected credentials are neither retried nor sent to the other endpoints
+
+	if requests := server.Requests(); len(requests) != 1 {
+		t.Fatalf("unexpected requests for rejected credentials: %+v", requests)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation/callback"
+)
+
+const fakeCallbackPayload = `{
+	"event": {
+		"type": "email-validation.completed",
+		"data": { "id": "job", "name": "newsletter", "status": "Completed", "noOfEntries": 1 }
+	}
+}`
+
+func postCallback(t *testing.T, handler http.Handler, method string, payload string) int {
+	request := httptest.NewRequest(method, "/callbacks", strings.NewReader(payload))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, request)
+
+	return recorder.Code
+}
+
+func TestCallbackHandler(t *testing.T) {
+	var mutex sync.Mutex
+	var events []callback.Event
+	failures := 1
+
+	handler := callback.NewHandler(func(ctx context.Context, event callback.Event) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if failures > 0 {
+			failures--
+			return errors.New("transient failure")
+		}
+
+		events = append(events, event)
+		return nil
+	}, nil)
+
+	// Malformed requests are rejected
+
+	if code := postCallback(t, handler, http.MethodGet, ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status code for GET: %d", code)
+	}
+
+	if code := postCallback(t, handler, http.MethodPost, "{ not json"); code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code for an invalid payload: %d", code)
+	}
+
+	if code := postCallback(t, handler, http.MethodPost, `{"event": {"type": "email-validation.completed", "data": {}}}`); code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code for a payload without job id: %d", code)
+	}
+
+	// A failure makes Verifalia deliver the event again, which is then dispatched; further duplicates are ignored
+
+	if code := postCallback(t, handler, http.MethodPost, fakeCallbackPayload); code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status code for a failed dispatch: %d", code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := postCallback(t, handler, http.MethodPost, fakeCallbackPayload); code != http.StatusOK {
+			t.Fatalf("unexpected status code for delivery #%d: %d", i, code)
+		}
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("unexpected number of dispatched events: %d", len(events))
+	}
+
+	event := events[0]
+
+	if event.Type != callback.EventType.EmailValidationCompleted || event.JobId != "job" || event.Name != "newsletter" ||
+		event.Status != emailValidation.JobStatus.Completed || event.Overview.NoOfEntries != 1 || event.Job != nil {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+}
+
+func TestCallbackHandlerFetchesJob(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		if id, found := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/email-validations/"), "/overview"); found {
+			_, _ = io.WriteString(w, fmt.Sprintf(`{ "id": "%s", "name": "newsletter", "status": "Completed", "noOfEntries": 1 }`, id))
+			return
+		}
+
+		_, _ = io.WriteString(w, fakeJobJson(strings.TrimPrefix(r.URL.Path, "/email-validations/"), emailValidation.JobStatus.Completed))
+	})
+
+	client := &emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+	events := make(chan callback.Event, 2)
+
+	onEvent := func(ctx context.Context, event callback.Event) error {
+		events <- event
+		return nil
+	}
+
+	// Older versions of the callback schema only include the job id
+
+	payload := `{"event": {"type": "email-validation.completed", "data": {"id": "job", "url": "https://api.verifalia.com/v2.5/email-validations/job"}}}`
+
+	// By default, only the overview of the job is fetched
+
+	handler := callback.NewHandler(onEvent, &callback.HandlerOptions{Client: client})
+
+	if code := postCallback(t, handler, http.MethodPost, payload); code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", code)
+	}
+
+	event := <-events
+
+	if event.Job != nil || event.Overview.Id != "job" || event.Overview.NoOfEntries != 1 || event.Name != "newsletter" ||
+		event.Status != emailValidation.JobStatus.Completed {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	if requests := server.Requests(); len(requests) != 1 || requests[0].Path != "/email-validations/job/overview" {
+		t.Fatalf("unexpected requests: %+v", requests)
+	}
+
+	// The entries are fetched only on request
+
+	handler = callback.NewHandler(onEvent, &callback.HandlerOptions{Client: client, FetchEntries: true})
+
+	if code := postCallback(t, handler, http.MethodPost, payload); code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", code)
+	}
+
+	event = <-events
+
+	if event.Job == nil || len(event.Job.Entries) != 1 || event.Overview.Id != "job" || event.Status != emailValidation.JobStatus.Completed {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+}
+
+func TestCallbackHandlerMissingJob(t *testing.T) {
+	for name, statusCode := range map[string]int{"not found": http.StatusNotFound, "gone": http.StatusGone} {
+		t.Run(name, func(t *testing.T) {
+			server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+				w.WriteHeader(statusCode)
+			})
+
+			client := &emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+			var dispatched int32
+
+			handler := callback.NewHandler(func(ctx context.Context, event callback.Event) error {
+				atomic.AddInt32(&dispatched, 1)
+				return nil
+			}, &callback.HandlerOptions{Client: client})
+
+			// The events of the deleted or expired jobs are acknowledged, so that Verifalia stops delivering them
+
+			if code := postCallback(t, handler, http.MethodPost, fakeCallbackPayload); code != http.StatusOK {
+				t.Fatalf("unexpected status code: %d", code)
+			}
+
+			if dispatched := atomic.LoadInt32(&dispatched); dispatched != 0 {
+				t.Fatalf("unexpected dispatched events: %d", dispatched)
+			}
+		})
+	}
+}
+
+func TestSignedCallbacks(t *testing.T) {
+	signer, err := callback.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var correlationIds []string
+	failures := 1
+
+	protected := signer.Middleware(callback.NewHandler(func(ctx context.Context, event callback.Event) error {
+		if failures > 0 {
+			failures--
+			return errors.New("transient failure")
+		}
+
+		correlationId, _ := callback.CorrelationIdFromContext(ctx)
+		correlationIds = append(correlationIds, correlationId)
+		return nil
+	}, nil))
+
+	deliver := func(target string) int {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(fakeCallbackPayload))
+		recorder := httptest.NewRecorder()
+
+		protected.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	callbackUrl, _ := url.Parse("https://receiver.internal/callbacks?tenant=42")
+	signedUrl, err := signer.SignUrl(*callbackUrl, "newsletter", time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsigned and tampered callbacks are rejected
+
+	if code := deliver(callbackUrl.String()); code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code for an unsigned callback: %d", code)
+	}
+
+	tamperedUrl := strings.Replace(signedUrl.String(), "tenant=42", "tenant=43", 1)
+
+	if code := deliver(tamperedUrl); code != http.StatusForbidden {
+		t.Fatalf("unexpected status code for a tampered callback: %d", code)
+	}
+
+	// A failed delivery can be retried, while the further deliveries of a successful one are acknowledged without
+	// reaching the handler
+
+	if code := deliver(signedUrl.String()); code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status code for a failed delivery: %d", code)
+	}
+
+	if code := deliver(signedUrl.String()); code != http.StatusOK {
+		t.Fatalf("unexpected status code for a valid delivery: %d", code)
+	}
+
+	if code := deliver(signedUrl.String()); code != http.StatusOK {
+		t.Fatalf("unexpected status code for a redelivered callback: %d", code)
+	}
+
+	if len(correlationIds) != 1 || correlationIds[0] != "newsletter" {
+		t.Fatalf("unexpected correlation ids: %v", correlationIds)
+	}
+
+	// Expired callbacks are rejected
+
+	expiredUrl, _ := signer.SignUrl(*callbackUrl, "newsletter", -time.Second)
+
+	if _, err := signer.Verify(expiredUrl); !errors.Is(err, callback.ErrExpiredToken) {
+		t.Fatalf("unexpected error for an expired callback: %v", err)
+	}
+
+	if code := deliver(expiredUrl.String()); code != http.StatusForbidden {
+		t.Fatalf("unexpected status code for an expired callback: %d", code)
+	}
+}
+
+func TestSignedCallbacksInFlight(t *testing.T) {
+	signer, err := callback.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processing := make(chan struct{})
+	release := make(chan struct{})
+	var dispatched int32
+
+	protected := signer.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&dispatched, 1) == 1 {
+			close(processing)
+			<-release
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	callbackUrl, _ := url.Parse("https://receiver.internal/callbacks")
+	signedUrl, err := signer.SignUrl(*callbackUrl, "newsletter", time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliver := func() int {
+		recorder := httptest.NewRecorder()
+		protected.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, signedUrl.String(), strings.NewReader(fakeCallbackPayload)))
+
+		return recorder.Code
+	}
+
+	first := make(chan int, 1)
+
+	go func() {
+		first <- deliver()
+	}()
+
+	<-processing
+
+	// A concurrent delivery of the callback being processed is asked to be delivered again later
+
+	if code := deliver(); code != http.StatusConflict {
+		t.Fatalf("unexpected status code for an in-flight callback: %d", code)
+	}
+
+	close(release)
+
+	if code := <-first; code != http.StatusOK {
+		t.Fatalf("unexpected status code for the first delivery: %d", code)
+	}
+
+	if code := deliver(); code != http.StatusOK || atomic.LoadInt32(&dispatched) != 1 {
+		t.Fatalf("unexpected redelivery: %d, %d dispatched", code, atomic.LoadInt32(&dispatched))
+	}
+}
+
+func TestWaitForCompletionNotification(t *testing.T) {
+	var completed int32
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		status := emailValidation.JobStatus.InProgress
+
+		if atomic.LoadInt32(&completed) == 1 {
+			status = emailValidation.JobStatus.Completed
+		}
+
+		_, _ = io.WriteString(w, fakeJobJson("job", status))
+	})
+
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+	dispatcher := callback.NewDispatcher()
+	handler := callback.NewHandler(dispatcher.HandleEvent, nil)
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		atomic.StoreInt32(&completed, 1)
+
+		if code := postCallback(t, handler, http.MethodPost, fakeCallbackPayload); code != http.StatusOK {
+			t.Errorf("unexpected status code: %d", code)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job := &emailValidation.Job{Overview: emailValidation.Overview{Id: "job", Status: emailValidation.JobStatus.InProgress}}
+
+	job, err := validations.WaitForCompletionWithOptions(job, &emailValidation.WaitingOptions{
+		Context:              ctx,
+		CompletionNotifier:   dispatcher,
+		FallbackPollInterval: time.Hour,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Overview.Status != emailValidation.JobStatus.Completed || len(server.Requests()) != 1 {
+		t.Fatalf("unexpected result: %v after %d requests", job.Overview.Status, len(server.Requests()))
+	}
+
+	// Notifications received before waiting are remembered
+
+	notification, unsubscribe := dispatcher.Subscribe("job")
+	defer unsubscribe()
+
+	select {
+	case <-notification:
+	default:
+		t.Fatal("the early notification has not been remembered")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia/credit"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+)
+
+// The tests below are meant to be run with the race detector: go test -race ./test
+
+func newFakeApiServer(t *testing.T) *recordingServer {
+	return newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/email-validations":
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = io.WriteString(w, fakeJobJson("5c3b6a2e-8f4d-4e5f-9a1b-2c3d4e5f6a7b", emailValidation.JobStatus.InProgress))
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/email-validations/"):
+			_, _ = io.WriteString(w, fakeJobJson(strings.TrimPrefix(r.URL.Path, "/email-validations/"), emailValidation.JobStatus.Completed))
+		case r.Method == http.MethodGet && r.URL.Path == "/credits/balance":
+			_, _ = io.WriteString(w, `{"creditPacks": 100.5, "freeCredits": 25, "freeCreditsResetIn": "05:00:00"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestConcurrentUsage(t *testing.T) {
+	server := newFakeApiServer(t)
+	refusing := newRefusingEndpoint(t)
+
+	restClient := buildFakeRestClient(refusing, server.URL, server.URL)
+	validations := emailValidation.Client{RestClient: restClient}
+	credits := credit.Client{RestClient: restClient}
+
+	const noOfGoroutines = 32
+	const noOfIterations = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, noOfGoroutines*noOfIterations*4)
+
+	for i := 0; i < noOfGoroutines; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < noOfIterations; j++ {
+				response, err := restClient.Invoke(rest.InvocationOptions{
+					Method:   http.MethodGet,
+					Resource: "credits/balance",
+				})
+
+				if err != nil {
+					errs <- err
+				} else {
+					_ = response.Body.Close()
+				}
+
+				job, err := validations.Submit("batman@gmail.com")
+
+				if err != nil {
+					errs <- err
+					continue
+				}
+
+				if _, err = validations.Get(job.Overview.Id); err != nil {
+					errs <- err
+				}
+
+				if _, err = credits.GetBalance(); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if health := restClient.(rest.HealthReporter).EndpointHealth(); health[0].State == rest.CircuitClosed {
+		t.Errorf("the failing endpoint is unexpectedly healthy: %+v", health[0])
+	}
+}
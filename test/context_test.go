@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/credit"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+)
+
+func TestContextCancelsInFlightRequests(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		// Hang until the client gives up on the request
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	})
+
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	// The polling request issued by WaitForCompletion must observe the waiting context
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	job := &emailValidation.Job{Overview: emailValidation.Overview{Id: "job", Status: emailValidation.JobStatus.InProgress}}
+	started := time.Now()
+
+	_, err := validations.WaitForCompletionWithOptions(job, &emailValidation.WaitingOptions{
+		Context: ctx,
+		WaitForNextPoll: func(overview emailValidation.Overview, ctx context.Context) error {
+			return nil
+		},
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(started) > 5*time.Second {
+		t.Fatalf("unexpected result after %v: %v", time.Since(started), err)
+	}
+
+	// Deletions can be bounded as well
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if err := validations.DeleteWithContext(ctx, "job"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCancellationIsNotUnreachable(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	})
+
+	assertContextError := func(t *testing.T, err error, expected error) {
+		if !errors.Is(err, expected) || errors.Is(err, verifalia.ErrAllEndpointsUnreachable) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		credits := credit.Client{RestClient: buildFakeRestClient(server.URL, server.URL)}
+		_, err := credits.GetBalanceWithContext(ctx)
+
+		assertContextError(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		credits := credit.Client{RestClient: buildFakeRestClient(server.URL, server.URL)}
+		_, err := credits.GetBalanceWithContext(ctx)
+
+		assertContextError(t, err, context.Canceled)
+	})
+
+	t.Run("operation timeout", func(t *testing.T) {
+		restClient := rest.NewMultiplexedRestClient(auth.NewBasicAuthProvider("username", "password"), "test",
+			[]string{server.URL, server.URL}, rest.WithOperationTimeout(100*time.Millisecond))
+
+		credits := credit.Client{RestClient: restClient}
+		_, err := credits.GetBalance()
+
+		assertContextError(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/credit"
+)
+
+func TestDailyUsage(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		if r.URL.Path != "/credits/daily-usage" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = fmt.Fprint(w, `{
+				"meta": { "isTruncated": true, "cursor": "next" },
+				"data": [
+					{ "date": "2024-01-08", "creditPacks": 12.5, "freeCredits": 200 },
+					{ "date": "2024-01-09", "creditPacks": 0, "freeCredits": 37.25 }
+				]
+			}`)
+		} else {
+			_, _ = fmt.Fprint(w, `{
+				"meta": { "isTruncated": false },
+				"data": [ { "date": "2024-01-10", "creditPacks": 1000.1, "freeCredits": 0 } ]
+			}`)
+		}
+	})
+
+	credits := credit.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	var usages []string
+
+	for usage, err := range credits.ListDailyUsage(context.Background(), credit.DailyUsageOptions{
+		Since: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2024, 1, 14, 23, 59, 0, 0, time.UTC),
+	}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usages = append(usages, fmt.Sprintf("%v=%v/%v", usage.Date.Format("01-02"), &usage.CreditPacks, &usage.FreeCredits))
+	}
+
+	if fmt.Sprint(usages) != "[01-08=12.5/200 01-09=0/37.25 01-10=1000.1/0]" {
+		t.Fatalf("unexpected usage: %v", usages)
+	}
+
+	for i, request := range server.Requests() {
+		query, _ := url.ParseQuery(request.Query)
+
+		if query.Get("date:since") != "2024-01-08" || query.Get("date:until") != "2024-01-14" || (i == 1 && query.Get("cursor") != "next") {
+			t.Fatalf("unexpected request #%d: %v", i, query)
+		}
+	}
+}
+
+// fakeClock is a credit.Clock whose time moves only through Advance; each call to After is signalled through waiting.
+type fakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	timers  []fakeTimer
+	waiting chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+		waiting: make(chan time.Duration, 16),
+	}
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.now
+}
+
+func (clock *fakeClock) After(duration time.Duration) <-chan time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	ch := make(chan time.Time, 1)
+	clock.timers = append(clock.timers, fakeTimer{at: clock.now.Add(duration), ch: ch})
+	clock.waiting <- duration
+
+	return ch
+}
+
+func (clock *fakeClock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = clock.now.Add(duration)
+	pending := clock.timers[:0]
+
+	for _, timer := range clock.timers {
+		if timer.at.After(clock.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.ch <- clock.now
+		}
+	}
+
+	clock.timers = pending
+}
+
+func (clock *fakeClock) waitForTimer(t *testing.T) time.Duration {
+	select {
+	case duration := <-clock.waiting:
+		return duration
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watcher did not wait for its next poll")
+		return 0
+	}
+}
+
+func TestBalanceWatcher(t *testing.T) {
+	balances := []string{
+		`{"creditPacks": 100, "freeCredits": 25, "freeCreditsResetIn": "00:10:00"}`,
+		`{"creditPacks": 80, "freeCredits": 0, "freeCreditsResetIn": "12:00:00"}`,
+		`{"creditPacks": 40, "freeCredits": 0, "freeCreditsResetIn": "11:00:00"}`,
+		`{"creditPacks": 500, "freeCredits": 0, "freeCreditsResetIn": "10:00:00"}`,
+		`{"creditPacks": 90, "freeCredits": 0, "freeCreditsResetIn": "09:00:00"}`,
+	}
+
+	var served int32
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		if r.URL.Path != "/credits/balance" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		idx := int(atomic.AddInt32(&served, 1)) - 1
+
+		if idx >= len(balances) {
+			idx = len(balances) - 1
+		}
+
+		_, _ = fmt.Fprint(w, balances[idx])
+	})
+
+	clock := newFakeClock()
+
+	var mutex sync.Mutex
+	var events []string
+
+	watcher := credit.NewWatcher(&credit.Client{RestClient: buildFakeRestClient(server.URL)}, credit.WatcherOptions{
+		Interval:   time.Hour,
+		Thresholds: []*decimal.Big{decimal.New(100, 0), decimal.New(50, 0)},
+		Clock:      clock,
+		OnEvent: func(event credit.WatcherEvent) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			events = append(events, fmt.Sprintf("%v:%v/%v", event.Type, event.Total, event.Threshold))
+		},
+	})
+
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	defer watcher.Stop()
+
+	if err := watcher.Start(context.Background()); err == nil {
+		t.Fatal("expected an error while starting the watcher twice")
+	}
+
+	// The first poll is scheduled at the reset of the free credits, which comes before the interval
+
+	if next := clock.waitForTimer(t); next != 10*time.Minute {
+		t.Fatalf("unexpected time until the next poll: %v", next)
+	}
+
+	balance, readOn := watcher.LastBalance()
+
+	if balance == nil || balance.CreditPacks.String() != "100" || !readOn.Equal(clock.Now()) {
+		t.Fatalf("unexpected last balance: %v on %v", balance, readOn)
+	}
+
+	clock.Advance(10 * time.Minute)
+	clock.waitForTimer(t)
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Hour)
+		clock.waitForTimer(t)
+	}
+
+	watcher.Stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	expected := "[FreeCreditsReset:80/<nil> LowBalance:80/100 LowBalance:40/50 LowBalance:90/100]"
+
+	if fmt.Sprint(events) != expected {
+		t.Fatalf("unexpected events: %v", events)
+	}
+}
+
+func TestBalanceWatcherStopFromEvent(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		_, _ = fmt.Fprint(w, `{"creditPacks": 10, "freeCredits": 0, "freeCreditsResetIn": "12:00:00"}`)
+	})
+
+	stopped := make(chan struct{})
+	var watcher *credit.Watcher
+
+	watcher = credit.NewWatcher(&credit.Client{RestClient: buildFakeRestClient(server.URL)}, credit.WatcherOptions{
+		Thresholds: []*decimal.Big{decimal.New(100, 0), decimal.New(50, 0)},
+		Clock:      newFakeClock(),
+		OnEvent: func(event credit.WatcherEvent) {
+			// Stopping the watcher upon the first low balance alert must neither deadlock nor raise further events
+
+			select {
+			case <-stopped:
+				t.Errorf("unexpected event after stopping the watcher: %+v", event)
+			default:
+			}
+
+			watcher.Stop()
+			close(stopped)
+		},
+	})
+
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopping the watcher from its event handler deadlocked")
+	}
+
+	watcher.Stop()
+
+	if requests := server.Requests(); len(requests) != 1 {
+		t.Fatalf("unexpected requests after stopping the watcher: %v", len(requests))
+	}
+}
+
+func TestBalanceWatcherDueReset(t *testing.T) {
+	// The reset of the free credits is imminent, and then the API keeps reporting it as due right now
+
+	var served int32
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		resetIn := "00:00:00"
+
+		if atomic.AddInt32(&served, 1) == 1 {
+			resetIn = "00:00:01"
+		}
+
+		_, _ = fmt.Fprintf(w, `{"creditPacks": 1000, "freeCredits": 0, "freeCreditsResetIn": %q}`, resetIn)
+	})
+
+	clock := newFakeClock()
+	var resets int32
+
+	watcher := credit.NewWatcher(&credit.Client{RestClient: buildFakeRestClient(server.URL)}, credit.WatcherOptions{
+		Interval: time.Hour,
+		Clock:    clock,
+		OnEvent: func(event credit.WatcherEvent) {
+			if event.Type == credit.WatcherEventType.FreeCreditsReset {
+				atomic.AddInt32(&resets, 1)
+			}
+		},
+	})
+
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	defer watcher.Stop()
+
+	// The imminent reset is awaited for a minimum delay, while the due ones are left to the regular readings
+
+	for _, expected := range []time.Duration{5 * time.Second, time.Hour, time.Hour} {
+		if next := clock.waitForTimer(t); next != expected {
+			t.Fatalf("unexpected time until the next poll: %v", next)
+		}
+
+		clock.Advance(expected)
+	}
+
+	clock.waitForTimer(t)
+
+	if raised := atomic.LoadInt32(&resets); raised != 1 {
+		t.Fatalf("unexpected number of reset events: %v", raised)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"github.com/verifalia/verifalia-go-sdk/verifalia"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/common"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
@@ -114,20 +115,23 @@ func TestSubmitMany(t *testing.T) {
 func TestListing(t *testing.T) {
 	client := buildClient()
 
-	results := client.EmailValidation.ListWithOptions(emailValidation.ListingOptions{
+	// Breaking out of the range-over-func iterator stops the listing without leaking any resource
+
+	overviews := client.EmailValidation.All(context.Background(), emailValidation.ListingOptions{
 		Direction: common.Backward,
 	})
 
 	count := 0
 
-	for result := range results {
+	for overview, err := range overviews {
 		t.Logf("Result...")
 
-		if result.Error != nil {
-			t.Error(result.Error)
+		if err != nil {
+			t.Error(err)
+			break
 		}
 
-		t.Logf("%v => %v\n", result.JobOverview.Id, result.JobOverview.SubmittedOn)
+		t.Logf("%v => %v\n", overview.Id, overview.SubmittedOn)
 
 		// Limit the iteration to the first 100 items
 
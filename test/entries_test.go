@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+)
+
+// newPagedJobServer returns a fake endpoint serving a completed job whose entries are split into the specified
+// number of segments; each segment after the first one is reachable through the cursor of the previous one.
+func newPagedJobServer(t *testing.T, noOfSegments int, segmentSize int) *recordingServer {
+	segment := func(no int) map[string]interface{} {
+		var data []map[string]interface{}
+
+		for i := 0; i < segmentSize; i++ {
+			index := no*segmentSize + i
+
+			data = append(data, map[string]interface{}{
+				"index":          index,
+				"inputData":      fmt.Sprintf("user%d@example.com", index),
+				"status":         "Success",
+				"classification": "Deliverable",
+			})
+		}
+
+		meta := map[string]interface{}{"isTruncated": no < noOfSegments-1}
+
+		if no < noOfSegments-1 {
+			meta["cursor"] = fmt.Sprintf("segment-%d", no+1)
+		}
+
+		return map[string]interface{}{"meta": meta, "data": data}
+	}
+
+	return newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		switch {
+		case r.URL.Path == "/email-validations/job":
+			var job map[string]interface{}
+
+			if err := json.Unmarshal([]byte(fakeJobJson("job", emailValidation.JobStatus.Completed)), &job); err != nil {
+				t.Error(err)
+			}
+
+			job["entries"] = segment(0)
+			_ = json.NewEncoder(w).Encode(job)
+
+		case r.URL.Path == "/email-validations/job/entries":
+			no := 0
+
+			if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+				no, _ = strconv.Atoi(strings.TrimPrefix(cursor, "segment-"))
+			}
+
+			if no >= noOfSegments {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			_ = json.NewEncoder(w).Encode(segment(no))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestJobEntriesSegments(t *testing.T) {
+	server := newPagedJobServer(t, 3, 4)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	// All the segments are collected into the job by default
+
+	job, err := validations.Get("job")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(job.Entries) != 12 {
+		t.Fatalf("unexpected number of entries: %d", len(job.Entries))
+	}
+
+	for i, entry := range job.Entries {
+		if entry.Index != i {
+			t.Fatalf("unexpected entry at position %d: %+v", i, entry)
+		}
+	}
+
+	// Segments can be streamed instead
+
+	var segmentSizes []int
+
+	job, err = validations.GetWithOptions("job", &emailValidation.RetrievalOptions{
+		EntrySegmentHandler: func(entries []emailValidation.Entry) error {
+			segmentSizes = append(segmentSizes, len(entries))
+			return nil
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(job.Entries) != 0 || fmt.Sprint(segmentSizes) != "[4 4 4]" {
+		t.Fatalf("unexpected streaming result: %d entries, segments %v", len(job.Entries), segmentSizes)
+	}
+
+	// Errors fetching a segment are reported
+
+	brokenServer := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		if r.URL.Path == "/email-validations/job/entries" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_, _ = fmt.Fprint(w, strings.Replace(fakeJobJson("job", emailValidation.JobStatus.Completed),
+			`"isTruncated": false`, `"isTruncated": true, "cursor": "segment-1"`, 1))
+	})
+
+	validations = emailValidation.Client{RestClient: buildFakeRestClient(brokenServer.URL)}
+
+	if _, err := validations.Get("job"); err == nil || !strings.Contains(err.Error(), "segment #2") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEntryFilter(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = fmt.Fprint(w, `{
+				"meta": { "isTruncated": true, "cursor": "next" },
+				"data": [
+					{ "index": 3, "inputData": "a@example.com", "status": "MailboxDoesNotExist", "classification": "Undeliverable" },
+					{ "index": 5, "inputData": "b@example.com", "status": "ServerIsCatchAll", "classification": "Risky" }
+				]
+			}`)
+		} else {
+			_, _ = fmt.Fprint(w, `{
+				"meta": { "isTruncated": false },
+				"data": [ { "index": 8, "inputData": "c@example.com", "status": "MailboxDoesNotExist", "classification": "Undeliverable" } ]
+			}`)
+		}
+	})
+
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	entries := validations.GetEntries("job", emailValidation.EntryFilter{
+		Statuses:         []string{emailValidation.Status.MailboxDoesNotExist, emailValidation.Status.ServerIsCatchAll},
+		ExcludedStatuses: []string{emailValidation.Status.Success},
+		Classifications:  []string{emailValidation.Classification.Undeliverable},
+		Limit:            2,
+	})
+
+	var indexes []int
+
+	for entries.Next() {
+		indexes = append(indexes, entries.Entry().Index)
+	}
+
+	if err := entries.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(indexes) != "[3 8]" || entries.Cursor() != "" {
+		t.Fatalf("unexpected entries: %v (cursor: %q)", indexes, entries.Cursor())
+	}
+
+	requests := server.Requests()
+
+	if len(requests) != 2 {
+		t.Fatalf("unexpected number of requests: %d", len(requests))
+	}
+
+	for i, request := range requests {
+		query, err := url.ParseQuery(request.Query)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if request.Path != "/email-validations/job/entries" ||
+			query.Get("status") != "MailboxDoesNotExist,ServerIsCatchAll" ||
+			query.Get("status:exclude") != "Success" ||
+			query.Get("limit") != "2" ||
+			(i == 1 && query.Get("cursor") != "next") {
+			t.Fatalf("unexpected request #%d: %+v", i, request)
+		}
+	}
+}
+
+func TestEntriesPreviousSegment(t *testing.T) {
+	server := newPagedJobServer(t, 3, 2)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	entries := validations.GetEntries("job", emailValidation.EntryFilter{})
+	var indexes []int
+
+	for entries.Next() && entries.Entry().Index < 3 {
+		indexes = append(indexes, entries.Entry().Index)
+	}
+
+	// Step back from the middle of the second segment to the beginning of the first one
+
+	if entries.SegmentCursor() != "segment-1" || !entries.PreviousSegment() || entries.SegmentCursor() != "" {
+		t.Fatalf("can't step back: %v", entries.Err())
+	}
+
+	for entries.Next() {
+		indexes = append(indexes, entries.Entry().Index)
+	}
+
+	if err := entries.Err(); err != nil || fmt.Sprint(indexes) != "[0 1 2 0 1 2 3 4 5]" {
+		t.Fatalf("unexpected entries: %v, %v", indexes, err)
+	}
+}
+
+func TestExportEntries(t *testing.T) {
+	const csv = "Index,Input,Status\n0,batman@gmail.com,Success\n"
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.Header().Set("Content-Type", r.Header.Get("Accept"))
+		_, _ = fmt.Fprint(w, csv)
+	})
+
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	var output strings.Builder
+
+	err := validations.ExportEntriesWithOptions("job", rest.ContentType.TextCsv, &output, &emailValidation.ExportOptions{
+		ExcludedStatuses: []string{emailValidation.Status.Duplicate},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+
+	if output.String() != csv || len(requests) != 1 || requests[0].Path != "/email-validations/job/entries" ||
+		requests[0].Header.Get("Accept") != rest.ContentType.TextCsv || requests[0].Query != "status%3Aexclude=Duplicate" {
+		t.Fatalf("unexpected export: %q, requests: %+v", output.String(), requests)
+	}
+
+	if err := validations.ExportEntries("job", rest.ContentType.ApplicationJson, &output); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
+
+func TestExportEntriesTimeout(t *testing.T) {
+	// The fake endpoint streams the exported file slowly, as it happens with the exports of large jobs
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.Header().Set("Content-Type", r.Header.Get("Accept"))
+		_, _ = fmt.Fprint(w, "Index,Input,Status\n")
+		w.(http.Flusher).Flush()
+
+		time.Sleep(300 * time.Millisecond)
+		_, _ = fmt.Fprint(w, "0,batman@gmail.com,Success\n")
+	})
+
+	client, err := verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+		verifalia.WithBaseUrls(server.URL),
+		verifalia.WithTimeout(100*time.Millisecond))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The timeout of the HTTP client covers the download of the file, unless the export replaces it
+
+	if err := client.EmailValidation.ExportEntries("job", rest.ContentType.TextCsv, io.Discard); err == nil {
+		t.Fatal("expected an error for an export exceeding the timeout of the HTTP client")
+	}
+
+	var output strings.Builder
+
+	err = client.EmailValidation.ExportEntriesWithOptions("job", rest.ContentType.TextCsv, &output, &emailValidation.ExportOptions{
+		Timeout: 5 * time.Second,
+	})
+
+	if err != nil || output.String() != "Index,Input,Status\n0,batman@gmail.com,Success\n" {
+		t.Fatalf("unexpected export: %q, %v", output.String(), err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+)
+
+func TestApiErrors(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusPaymentRequired)
+		_, _ = io.WriteString(w, `{"type":"https://verifalia.com/problems/credits","title":"Insufficient credits","status":402,"traceId":"00-abc-01"}`)
+	})
+
+	client, err := verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+		verifalia.WithBaseUrls(server.URL))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.EmailValidation.Submit("batman@gmail.com")
+
+	if !errors.Is(err, verifalia.ErrInsufficientCredits) || errors.Is(err, verifalia.ErrNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var apiError *verifalia.APIError
+
+	if !errors.As(err, &apiError) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+
+	if apiError.StatusCode != http.StatusPaymentRequired || apiError.Method != http.MethodPost ||
+		apiError.RequestId != "00-abc-01" || apiError.Problem == nil || apiError.Problem.Title != "Insufficient credits" {
+		t.Fatalf("unexpected API error: %+v", apiError)
+	}
+}
+
+func TestUnreachableEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client, err := verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+		verifalia.WithBaseUrls(server.URL, server.URL))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Credit.GetBalance()
+
+	var unreachableError *verifalia.EndpointsUnreachableError
+
+	if !errors.Is(err, verifalia.ErrAllEndpointsUnreachable) || !errors.As(err, &unreachableError) || len(unreachableError.Errors) == 0 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMissingJobs(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		switch r.URL.Path {
+		case "/email-validations/missing", "/email-validations/missing/overview":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusGone)
+		}
+	})
+
+	client, err := verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+		verifalia.WithBaseUrls(server.URL))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job, err := client.EmailValidation.Get("missing"); job != nil || !errors.Is(err, verifalia.ErrNotFound) {
+		t.Errorf("unexpected result for a missing job: %v, %v", job, err)
+	}
+
+	if overview, err := client.EmailValidation.GetOverview("missing"); overview != nil || !errors.Is(err, verifalia.ErrNotFound) {
+		t.Errorf("unexpected result for a missing job overview: %v, %v", overview, err)
+	}
+
+	if job, err := client.EmailValidation.Get("expired"); job != nil || !errors.Is(err, verifalia.ErrGone) {
+		t.Errorf("unexpected result for an expired job: %v, %v", job, err)
+	}
+
+	// The job expires while waiting for its completion
+
+	var job emailValidation.Job
+	job.Overview.Id = "expired"
+	job.Overview.Status = emailValidation.JobStatus.InProgress
+
+	result, err := client.EmailValidation.WaitForCompletionWithOptions(&job, &emailValidation.WaitingOptions{
+		WaitForNextPoll: func(overview emailValidation.Overview, ctx context.Context) error {
+			return nil
+		},
+	})
+
+	if result != nil || !errors.Is(err, verifalia.ErrGone) {
+		t.Errorf("unexpected result while waiting for an expired job: %v, %v", result, err)
+	}
+}
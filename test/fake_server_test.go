@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Helpers for the tests which run against local, fake Verifalia API endpoints
+
+// newDroppingServer creates a server which reads the whole request body and then drops the connection, without
+// sending any response: this forces the client to fail over to the next endpoint, after consuming the body.
+func newDroppingServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		_ = conn.Close()
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newRefusingEndpoint returns the URL of a local endpoint which refuses the connections: the requests sent to it fail
+// before reaching any server, thus the client can safely fail over to the next endpoint, whatever the method.
+func newRefusingEndpoint(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint := "http://" + listener.Addr().String()
+	_ = listener.Close()
+
+	return endpoint
+}
+
+// recordedRequest is a request received by a recordingServer.
+type recordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   string
+}
+
+// recordingServer is a fake endpoint which keeps track of the received requests and replies through the
+// configured handler.
+type recordingServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests []recordedRequest
+}
+
+func newRecordingServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, body string)) *recordingServer {
+	server := &recordingServer{}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			t.Error(err)
+		}
+
+		server.mutex.Lock()
+		server.requests = append(server.requests, recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   string(data),
+		})
+		server.mutex.Unlock()
+
+		handler(w, r, string(data))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *recordingServer) Requests() []recordedRequest {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]recordedRequest(nil), server.requests...)
+}
+
+// fakeJobJson returns the JSON representation of an email validation job with the specified id and status.
+func fakeJobJson(id string, status string) string {
+	return fmt.Sprintf(`{
+		"overview": {
+			"id": "%s",
+			"submittedOn": "2024-01-18T10:00:00Z",
+			"createdOn": "2024-01-18T10:00:00Z",
+			"quality": "Standard",
+			"retention": "00:30:00",
+			"deduplication": "Off",
+			"status": "%s",
+			"noOfEntries": 1
+		},
+		"entries": {
+			"meta": { "isTruncated": false },
+			"data": [ { "index": 0, "inputData": "batman@gmail.com", "status": "Success", "classification": "Deliverable" } ]
+		}
+	}`, id, status)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+)
+
+// newPagedListingServer returns a fake endpoint serving a listing of jobs split into the specified number of segments.
+func newPagedListingServer(t *testing.T, noOfSegments int) *recordingServer {
+	return newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		no := 0
+
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			_, _ = fmt.Sscanf(cursor, "segment-%d", &no)
+		}
+
+		meta := `{ "isTruncated": false }`
+
+		if no < noOfSegments-1 {
+			meta = fmt.Sprintf(`{ "isTruncated": true, "cursor": "segment-%d" }`, no+1)
+		}
+
+		_, _ = fmt.Fprintf(w, `{
+			"meta": %s,
+			"data": [ {
+				"id": "job-%d",
+				"submittedOn": "2024-01-18T10:00:00Z",
+				"createdOn": "2024-01-18T10:00:00Z",
+				"quality": "Standard",
+				"retention": "00:30:00",
+				"deduplication": "Off",
+				"status": "Completed",
+				"noOfEntries": 1
+			} ]
+		}`, meta, no)
+	})
+}
+
+func TestListingFilters(t *testing.T) {
+	server := newPagedListingServer(t, 2)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	var ids []string
+
+	for result := range validations.ListWithOptions(emailValidation.ListingOptions{
+		Limit:            10,
+		OrderBy:          emailValidation.CompletedOn,
+		CreatedOnSince:   time.Date(2024, 1, 8, 15, 30, 0, 0, time.UTC),
+		CreatedOnUntil:   time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+		Statuses:         []string{emailValidation.JobStatus.Completed},
+		ExcludedStatuses: []string{emailValidation.JobStatus.Expired, emailValidation.JobStatus.Deleted},
+		Owner:            "user-x",
+		Name:             "newsletter",
+	}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+
+		ids = append(ids, result.JobOverview.Id)
+	}
+
+	if fmt.Sprint(ids) != "[job-0 job-1]" {
+		t.Fatalf("unexpected jobs: %v", ids)
+	}
+
+	requests := server.Requests()
+
+	for i, request := range requests {
+		query, err := url.ParseQuery(request.Query)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if query.Get("limit") != "10" || query.Get("createdOn:since") != "2024-01-08" || query.Get("createdOn:until") != "2024-01-14" ||
+			query.Get("status") != "Completed" || query.Get("status:exclude") != "Expired,Deleted" ||
+			query.Get("owner") != "user-x" || query.Get("name") != "newsletter" {
+			t.Fatalf("unexpected filters for request #%d: %v", i, query)
+		}
+
+		if (i == 0 && query.Get("sort") != "completedOn") || (i == 1 && query.Get("cursor") != "segment-1") {
+			t.Fatalf("unexpected request #%d: %v", i, query)
+		}
+	}
+}
+
+// waitForGoroutines waits until the number of running goroutines drops to the specified baseline.
+func waitForGoroutines(t *testing.T, baseline int) {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("leaked goroutines: %d running, %d expected", runtime.NumGoroutine(), baseline)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListingDoesNotLeak(t *testing.T) {
+	server := newPagedListingServer(t, 100)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	// Warm up the connection pool, so that its goroutines are part of the baseline
+
+	for result := range validations.ListWithOptions(emailValidation.ListingOptions{}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	// Stopping an iterator early
+
+	for i := 0; i < 10; i++ {
+		jobs := validations.ListOverviews(emailValidation.ListingOptions{})
+
+		for count := 0; jobs.Next() && count < 5; count++ {
+		}
+
+		if err := jobs.Close(); err != nil || jobs.Err() != nil || jobs.Next() {
+			t.Fatalf("unexpected state after closing the iterator: %v, %v", err, jobs.Err())
+		}
+	}
+
+	waitForGoroutines(t, baseline)
+
+	// Stopping a channel listing early, through its context
+
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		count := 0
+
+		for range validations.ListWithOptions(emailValidation.ListingOptions{Context: ctx}) {
+			if count++; count == 5 {
+				break
+			}
+		}
+
+		cancel()
+	}
+
+	waitForGoroutines(t, baseline)
+
+	if requests := len(server.Requests()); requests > 100+20*7 {
+		t.Fatalf("too many requests: %d", requests)
+	}
+}
+
+func TestOverviewIteratorClose(t *testing.T) {
+	blocked := make(chan struct{})
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		close(blocked)
+		<-r.Context().Done()
+	})
+
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+	jobs := validations.ListOverviews(emailValidation.ListingOptions{})
+
+	go func() {
+		<-blocked
+		_ = jobs.Close()
+	}()
+
+	// Closing the iterator aborts the in-flight request
+
+	if jobs.Next() || jobs.Err() != nil {
+		t.Fatalf("unexpected state after closing the iterator: %v", jobs.Err())
+	}
+}
+
+func TestRangeOverFuncIterators(t *testing.T) {
+	listingServer := newPagedListingServer(t, 3)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(listingServer.URL)}
+
+	var ids []string
+
+	for overview, err := range validations.All(context.Background(), emailValidation.ListingOptions{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, overview.Id)
+	}
+
+	if fmt.Sprint(ids) != "[job-0 job-1 job-2]" {
+		t.Fatalf("unexpected jobs: %v", ids)
+	}
+
+	// Breaking out of the loop stops requesting the subsequent segments
+
+	requests := len(listingServer.Requests())
+
+	for range validations.All(context.Background(), emailValidation.ListingOptions{}) {
+		break
+	}
+
+	if len(listingServer.Requests()) != requests+1 {
+		t.Fatalf("unexpected number of requests: %d", len(listingServer.Requests())-requests)
+	}
+
+	// Entries
+
+	jobServer := newPagedJobServer(t, 3, 4)
+	validations = emailValidation.Client{RestClient: buildFakeRestClient(jobServer.URL)}
+	count := 0
+
+	for entry, err := range validations.AllEntries(context.Background(), "job", emailValidation.EntryFilter{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.Index != count {
+			t.Fatalf("unexpected entry at position %d: %+v", count, entry)
+		}
+
+		count++
+	}
+
+	if count != 12 {
+		t.Fatalf("unexpected number of entries: %d", count)
+	}
+
+	// Errors are yielded
+
+	for _, err := range validations.AllEntries(context.Background(), "missing", emailValidation.EntryFilter{}) {
+		if !errors.Is(err, rest.ErrNotFound) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+)
+
+func TestClientOptions(t *testing.T) {
+	server := newFakeApiServer(t)
+	roundTrips := 0
+
+	client, err := verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+		verifalia.WithBaseUrls(server.URL),
+		verifalia.WithTransport(roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			roundTrips++
+			return http.DefaultTransport.RoundTrip(request)
+		})),
+		verifalia.WithUserAgentSuffix("acme-crm/1.2"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.Credit.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+
+	if len(requests) != 1 || roundTrips != 1 || !strings.HasSuffix(requests[0].Header.Get("User-Agent"), " acme-crm/1.2") {
+		t.Fatalf("unexpected requests: %+v", requests)
+	}
+
+	// Proxy settings can't be applied to arbitrary transports
+
+	_, err = verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+		verifalia.WithTransport(roundTripperFunc(http.DefaultTransport.RoundTrip)),
+		verifalia.WithProxyUrl(nil))
+
+	if err == nil {
+		t.Fatal("expected an error while applying proxy settings to a custom transport")
+	}
+}
+
+func TestBearerClientOptions(t *testing.T) {
+	server := newFakeTokenServer(t, nil, false)
+	var roundTrips int32
+
+	// The token is acquired through the configured transport and base URLs, in place of the provider's own ones
+
+	provider := auth.NewBearerAuthProvider("username", "password", []string{newRefusingEndpoint(t)}, nil)
+
+	client, err := verifalia.NewClientWithOptions(provider,
+		verifalia.WithBaseUrls(server.URL),
+		verifalia.WithTransport(roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&roundTrips, 1)
+			return http.DefaultTransport.RoundTrip(request)
+		})))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.Credit.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.tokenRequests("/auth/tokens") != 1 || atomic.LoadInt32(&roundTrips) != 2 {
+		t.Fatalf("unexpected requests: %v round trips, %+v", roundTrips, server.Requests())
+	}
+}
+
+type roundTripperFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia/common"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+)
+
+// fakeSegmentFetcher serves a listing of numbers split into segments of the requested size, walking it forward or
+// backward according to the direction of the requested cursor.
+func fakeSegmentFetcher(total int, requests *[]common.SegmentRequest) common.SegmentFetcher[int] {
+	return func(ctx context.Context, request common.SegmentRequest) (*common.ListingSegment[int], error) {
+		*requests = append(*requests, request)
+
+		start, step := 0, 1
+
+		if request.Cursor != nil {
+			_, _ = fmt.Sscanf(request.Cursor.Cursor, "%d", &start)
+
+			if request.Cursor.Direction == common.Backward {
+				step = -1
+			}
+		}
+
+		var data []int
+		position := start
+
+		for ; position >= 0 && position < total && len(data) < request.Limit; position += step {
+			data = append(data, position)
+		}
+
+		segment := &common.ListingSegment[int]{Meta: &common.ListingMeta{}, Data: &data}
+
+		if position >= 0 && position < total {
+			segment.Meta.Cursor = fmt.Sprint(position)
+			segment.Meta.IsTruncated = true
+		}
+
+		return segment, nil
+	}
+}
+
+func TestPaginatorCursors(t *testing.T) {
+	var requests []common.SegmentRequest
+	fetch := fakeSegmentFetcher(10, &requests)
+
+	// Stop after the first segment, saving a checkpoint
+
+	paginator := common.NewPaginatorWithOptions(fetch, common.PaginatorOptions{Limit: 4})
+
+	if paginator.Cursor() != nil {
+		t.Fatal("unexpected cursor before starting")
+	}
+
+	items, err := paginator.NextSegment(context.Background())
+
+	if err != nil || fmt.Sprint(items) != "[0 1 2 3]" {
+		t.Fatalf("unexpected first segment: %v, %v", items, err)
+	}
+
+	checkpoint := paginator.Cursor()
+
+	if checkpoint == nil || checkpoint.Cursor != "4" || checkpoint.Direction != common.Forward {
+		t.Fatalf("unexpected checkpoint: %+v", checkpoint)
+	}
+
+	// Resume from the checkpoint
+
+	var resumed []int
+
+	for item, err := range common.NewPaginatorWithOptions(fetch, common.PaginatorOptions{Cursor: checkpoint, Limit: 4}).All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resumed = append(resumed, item)
+	}
+
+	if fmt.Sprint(resumed) != "[4 5 6 7 8 9]" {
+		t.Fatalf("unexpected resumed items: %v", resumed)
+	}
+
+	// Step backward
+
+	backward := common.NewPaginatorWithOptions(fetch, common.PaginatorOptions{
+		Cursor: &common.ListingCursor{Direction: common.Backward, Cursor: "5"},
+		Limit:  3,
+	})
+
+	items, _ = backward.NextSegment(context.Background())
+
+	if fmt.Sprint(items) != "[5 4 3]" || backward.Cursor().Direction != common.Backward {
+		t.Fatalf("unexpected backward segment: %v, cursor %+v", items, backward.Cursor())
+	}
+
+	for _, request := range requests {
+		if request.Limit != 4 && request.Limit != 3 {
+			t.Fatalf("unexpected page size: %+v", request)
+		}
+	}
+}
+
+func TestPaginatorPreviousSegment(t *testing.T) {
+	var requests []common.SegmentRequest
+	paginator := common.NewPaginatorWithOptions(fakeSegmentFetcher(10, &requests), common.PaginatorOptions{Limit: 4})
+
+	if paginator.HasPrevious() || paginator.SegmentCursor() != nil {
+		t.Fatal("unexpected previous segment before starting")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := paginator.NextSegment(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cursor := paginator.SegmentCursor(); cursor == nil || cursor.Cursor != "8" || paginator.HasMore() {
+		t.Fatalf("unexpected cursor of the last segment: %+v", cursor)
+	}
+
+	// Step back from the last segment up to the first one
+
+	var segments []string
+
+	for paginator.HasPrevious() {
+		items, err := paginator.PreviousSegment(context.Background())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		segments = append(segments, fmt.Sprint(items))
+	}
+
+	if fmt.Sprint(segments) != "[[4 5 6 7] [0 1 2 3]]" || paginator.SegmentCursor() != nil {
+		t.Fatalf("unexpected previous segments: %v", segments)
+	}
+
+	if items, err := paginator.PreviousSegment(context.Background()); items != nil || err != nil {
+		t.Fatalf("unexpected segment before the first one: %v, %v", items, err)
+	}
+
+	// Then walk forward again
+
+	items, _ := paginator.NextSegment(context.Background())
+
+	if fmt.Sprint(items) != "[4 5 6 7]" || !paginator.HasPrevious() || paginator.SegmentCursor().Cursor != "4" {
+		t.Fatalf("unexpected segment after stepping back: %v", items)
+	}
+
+	// The cursor of the current segment resumes the listing from that same segment
+
+	resumed := common.NewPaginatorWithOptions(fakeSegmentFetcher(10, &requests), common.PaginatorOptions{
+		Cursor: paginator.SegmentCursor(),
+		Limit:  4,
+	})
+
+	if items, _ := resumed.NextSegment(context.Background()); fmt.Sprint(items) != "[4 5 6 7]" || resumed.HasPrevious() {
+		t.Fatalf("unexpected resumed segment: %v", items)
+	}
+}
+
+func TestListingPreviousSegment(t *testing.T) {
+	server := newPagedListingServer(t, 3)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	jobs := validations.ListOverviews(emailValidation.ListingOptions{Limit: 1})
+	defer jobs.Close()
+
+	if jobs.PreviousSegment() {
+		t.Fatal("unexpected previous segment before starting")
+	}
+
+	var ids []string
+
+	for jobs.Next() {
+		ids = append(ids, jobs.Overview().Id)
+	}
+
+	// Step back to the segment before the last one, walking the listing forward from there
+
+	if !jobs.PreviousSegment() {
+		t.Fatalf("can't step back: %v", jobs.Err())
+	}
+
+	for jobs.Next() {
+		ids = append(ids, jobs.Overview().Id)
+	}
+
+	if fmt.Sprint(ids) != "[job-0 job-1 job-2 job-1 job-2]" || jobs.SegmentCursor().Cursor != "segment-2" {
+		t.Fatalf("unexpected jobs: %v", ids)
+	}
+}
+
+func TestListingResume(t *testing.T) {
+	server := newPagedListingServer(t, 3)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	jobs := validations.ListOverviews(emailValidation.ListingOptions{Limit: 1})
+
+	if !jobs.Next() || jobs.Overview().Id != "job-0" {
+		t.Fatalf("unexpected first job: %+v, %v", jobs.Overview(), jobs.Err())
+	}
+
+	checkpoint := jobs.Cursor()
+	_ = jobs.Close()
+
+	jobs = validations.ListOverviews(emailValidation.ListingOptions{Limit: 1, Cursor: checkpoint})
+	defer jobs.Close()
+
+	var ids []string
+
+	for jobs.Next() {
+		ids = append(ids, jobs.Overview().Id)
+	}
+
+	if fmt.Sprint(ids) != "[job-1 job-2]" {
+		t.Fatalf("unexpected resumed jobs: %v", ids)
+	}
+
+	// Backward cursors are sent as such
+
+	jobs = validations.ListOverviews(emailValidation.ListingOptions{
+		Cursor: &common.ListingCursor{Direction: common.Backward, Cursor: "segment-1"},
+	})
+
+	jobs.Next()
+
+	requests := server.Requests()
+	query, _ := url.ParseQuery(requests[len(requests)-1].Query)
+
+	if query.Get("cursor:prev") != "segment-1" || query.Get("cursor") != "" {
+		t.Fatalf("unexpected backward request: %v", query)
+	}
+}
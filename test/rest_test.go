@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+)
+
+func buildFakeRestClient(baseUrls ...string) rest.Client {
+	return rest.NewMultiplexedRestClient(auth.NewBasicAuthProvider("username", "password"), "test", baseUrls)
+}
+
+func TestFailoverResendsBody(t *testing.T) {
+	const payload = `{"entries":[{"inputData":"batman@gmail.com"}]}`
+
+	bodies := map[string]func() rest.InvocationOptions{
+		"seekable": func() rest.InvocationOptions {
+			return rest.InvocationOptions{Body: bytes.NewReader([]byte(payload))}
+		},
+		"one-shot": func() rest.InvocationOptions {
+			return rest.InvocationOptions{Body: io.MultiReader(strings.NewReader(payload[:10]), strings.NewReader(payload[10:]))}
+		},
+		"factory": func() rest.InvocationOptions {
+			return rest.InvocationOptions{GetBody: func() (io.Reader, error) {
+				return strings.NewReader(payload), nil
+			}}
+		},
+	}
+
+	for name, buildOptions := range bodies {
+		t.Run(name, func(t *testing.T) {
+			dropping := newDroppingServer(t)
+			recording := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			options := buildOptions()
+			options.Method = http.MethodPost
+			options.Resource = "email-validations"
+
+			// The body has been consumed by the dropping server, which may have processed the request: resending it
+			// requires RetryNonIdempotent
+
+			client := rest.NewMultiplexedRestClient(auth.NewBasicAuthProvider("username", "password"), "test",
+				[]string{dropping.URL, recording.URL},
+				rest.WithRetryPolicy(&rest.ExponentialBackoffRetryPolicy{MaxAttempts: 2, RetryNonIdempotent: true}))
+
+			response, err := client.Invoke(options)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_ = response.Body.Close()
+
+			requests := recording.Requests()
+
+			if len(requests) != 1 || requests[0].Body != payload {
+				t.Fatalf("unexpected requests after failover: %+v", requests)
+			}
+		})
+	}
+}
+
+func TestReauthenticationResendsBody(t *testing.T) {
+	const payload = `{"entries":[{"inputData":"batman@gmail.com"}]}`
+
+	rejected := false
+	recording := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		if !rejected {
+			rejected = true
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	provider := &rotatingAuthProvider{Provider: auth.NewBasicAuthProvider("username", "password")}
+	client := rest.NewMultiplexedRestClient(provider, "test", []string{recording.URL})
+
+	response, err := client.Invoke(rest.InvocationOptions{
+		Method:   http.MethodPost,
+		Resource: "email-validations",
+		Body:     io.MultiReader(strings.NewReader(payload)),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = response.Body.Close()
+
+	requests := recording.Requests()
+
+	if provider.recoveries != 1 || len(requests) != 2 || requests[0].Body != payload || requests[1].Body != payload {
+		t.Fatalf("unexpected requests after re-authentication: %+v", requests)
+	}
+}
+
+func TestSubmissionFailover(t *testing.T) {
+	refusing := newRefusingEndpoint(t)
+	recording := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, fakeJobJson("b2d4c5b5-5e1b-4ae2-b4a6-0b9c2b1f4c1a", emailValidation.JobStatus.InProgress))
+	})
+
+	client := emailValidation.Client{RestClient: buildFakeRestClient(refusing, recording.URL)}
+
+	t.Run("many", func(t *testing.T) {
+		job, err := client.SubmitMany([]string{"batman@gmail.com", "robin@gmail.com"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requests := recording.Requests()
+		lastBody := requests[len(requests)-1].Body
+
+		if job.Overview.Id == "" || !strings.Contains(lastBody, "batman@gmail.com") || !strings.Contains(lastBody, "robin@gmail.com") {
+			t.Fatalf("unexpected body after failover: %v", lastBody)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		reader := io.MultiReader(strings.NewReader("batman@gmail.com\nrobin@gmail.com"))
+
+		_, err := client.SubmitFileReaderWithOptions(reader, nil, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requests := recording.Requests()
+		lastBody := requests[len(requests)-1].Body
+
+		if !strings.Contains(lastBody, "batman@gmail.com\nrobin@gmail.com") {
+			t.Fatalf("unexpected body after failover: %v", lastBody)
+		}
+	})
+}
+
+// rotatingAuthProvider simulates a provider which can recover from unauthorized requests, for example by
+// reloading its rotated credentials.
+type rotatingAuthProvider struct {
+	auth.Provider
+	recoveries int
+}
+
+func (provider *rotatingAuthProvider) HandleUnauthorizedRequest() error {
+	provider.recoveries++
+	return nil
+}
+
+func TestUnauthorizedRecovery(t *testing.T) {
+	newServer := func(statusCodes ...int) *recordingServer {
+		var mutex sync.Mutex
+
+		return newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			w.WriteHeader(statusCodes[0])
+
+			if len(statusCodes) > 1 {
+				statusCodes = statusCodes[1:]
+			}
+		})
+	}
+
+	invoke := func(provider auth.Provider, server *recordingServer) (*http.Response, error) {
+		response, err := rest.NewMultiplexedRestClient(provider, "test", []string{server.URL}).Invoke(rest.InvocationOptions{
+			Method:   http.MethodGet,
+			Resource: "credits/balance",
+		})
+
+		if err == nil {
+			_ = response.Body.Close()
+		}
+
+		return response, err
+	}
+
+	t.Run("recovered", func(t *testing.T) {
+		server := newServer(http.StatusUnauthorized, http.StatusOK)
+		provider := &rotatingAuthProvider{Provider: auth.NewBasicAuthProvider("username", "password")}
+
+		response, err := invoke(provider, server)
+
+		if err != nil || response.StatusCode != http.StatusOK || provider.recoveries != 1 || len(server.Requests()) != 2 {
+			t.Fatalf("unexpected recovery: %v, %v recoveries", err, provider.recoveries)
+		}
+	})
+
+	t.Run("rejected again", func(t *testing.T) {
+		server := newServer(http.StatusUnauthorized)
+		provider := &rotatingAuthProvider{Provider: auth.NewBasicAuthProvider("username", "password")}
+
+		_, err := invoke(provider, server)
+
+		if !errors.Is(err, rest.ErrAuthenticationFailed) || provider.recoveries != 1 || len(server.Requests()) != 2 {
+			t.Fatalf("unexpected recovery: %v, %v recoveries", err, provider.recoveries)
+		}
+	})
+
+	t.Run("unrecoverable", func(t *testing.T) {
+		server := newServer(http.StatusUnauthorized)
+
+		_, err := invoke(auth.NewBasicAuthProvider("username", "password"), server)
+
+		if !errors.Is(err, rest.ErrAuthenticationFailed) || len(server.Requests()) != 1 {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		// A new token or credential would be rejected all the same, so the provider is not involved
+
+		server := newServer(http.StatusForbidden)
+		provider := &rotatingAuthProvider{Provider: auth.NewBasicAuthProvider("username", "password")}
+
+		response, err := invoke(provider, server)
+
+		if err != nil || response.StatusCode != http.StatusForbidden || provider.recoveries != 0 || len(server.Requests()) != 1 {
+			t.Fatalf("unexpected recovery: %v, %v recoveries", err, provider.recoveries)
+		}
+	})
+}
+
+func TestRetryPolicy(t *testing.T) {
+	unavailable := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	failing := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	healthy := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("retryable status", func(t *testing.T) {
+		response, err := buildFakeRestClient(unavailable.URL, healthy.URL).Invoke(rest.InvocationOptions{
+			Method:   http.MethodPost,
+			Resource: "email-validations",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status code: %v", response.StatusCode)
+		}
+	})
+
+	t.Run("non-idempotent", func(t *testing.T) {
+		response, err := buildFakeRestClient(failing.URL, healthy.URL).Invoke(rest.InvocationOptions{
+			Method:   http.MethodPost,
+			Resource: "email-validations",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("unexpected status code: %v", response.StatusCode)
+		}
+	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		response, err := buildFakeRestClient(failing.URL, healthy.URL).Invoke(rest.InvocationOptions{
+			Method:   http.MethodGet,
+			Resource: "email-validations",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status code: %v", response.StatusCode)
+		}
+	})
+}
+
+func TestRetryPolicyAttempts(t *testing.T) {
+	unavailable := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	healthy := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	invoke := func(baseUrls ...string) int {
+		response, err := buildFakeRestClient(baseUrls...).Invoke(rest.InvocationOptions{
+			Method:   http.MethodGet,
+			Resource: "credits/balance",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = response.Body.Close()
+		return response.StatusCode
+	}
+
+	// By default, each of the configured endpoints is tried once, however many they are
+
+	baseUrls := []string{newRefusingEndpoint(t), newRefusingEndpoint(t), newRefusingEndpoint(t), newRefusingEndpoint(t), healthy.URL}
+
+	if statusCode := invoke(baseUrls...); statusCode != http.StatusOK {
+		t.Fatalf("unexpected status code: %v", statusCode)
+	}
+
+	if statusCode := invoke(unavailable.URL); statusCode != http.StatusServiceUnavailable || len(unavailable.Requests()) != 1 {
+		t.Fatalf("unexpected attempts against a single endpoint: %v, %+v", statusCode, unavailable.Requests())
+	}
+}
+
+func TestNonIdempotentTransportErrors(t *testing.T) {
+	dropping := newDroppingServer(t)
+	refusing := newRefusingEndpoint(t)
+	healthy := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	invoke := func(method string, baseUrls ...string) error {
+		response, err := buildFakeRestClient(baseUrls...).Invoke(rest.InvocationOptions{
+			Method:   method,
+			Resource: "email-validations",
+			Body:     strings.NewReader(`{"entries":[{"inputData":"batman@gmail.com"}]}`),
+		})
+
+		if err == nil {
+			_ = response.Body.Close()
+		}
+
+		return err
+	}
+
+	// The dropping server may have processed the request, which is thus retried only if idempotent
+
+	if err := invoke(http.MethodPost, dropping.URL, healthy.URL); !errors.Is(err, rest.ErrAllEndpointsUnreachable) || len(healthy.Requests()) != 0 {
+		t.Fatalf("unexpected failover of a non-idempotent request: %v", err)
+	}
+
+	if err := invoke(http.MethodPut, dropping.URL, healthy.URL); err != nil || len(healthy.Requests()) != 1 {
+		t.Fatalf("unexpected failover of an idempotent request: %v", err)
+	}
+
+	// Requests which could not connect to the endpoint are always retried
+
+	if err := invoke(http.MethodPost, refusing, healthy.URL); err != nil || len(healthy.Requests()) != 2 {
+		t.Fatalf("unexpected failover of a request which did not connect: %v", err)
+	}
+}
+
+func TestLongPollingLatency(t *testing.T) {
+	slow := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client := rest.NewMultiplexedRestClient(auth.NewBasicAuthProvider("username", "password"), "test", []string{slow.URL})
+
+	invoke := func(queryParams url.Values) {
+		response, err := client.Invoke(rest.InvocationOptions{
+			Method:      http.MethodGet,
+			Resource:    "email-validations/b2d4c5b5-5e1b-4ae2-b4a6-0b9c2b1f4c1a",
+			QueryParams: queryParams,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = response.Body.Close()
+	}
+
+	// Requests held by the API on purpose do not make the endpoint look slow
+
+	invoke(url.Values{"waitTime": {"30"}})
+
+	if latency := client.EndpointHealth()[0].Latency; latency != 0 {
+		t.Fatalf("unexpected latency after a long poll: %v", latency)
+	}
+
+	invoke(url.Values{"waitTime": {"0"}})
+
+	if latency := client.EndpointHealth()[0].Latency; latency < 100*time.Millisecond {
+		t.Fatalf("unexpected latency after a regular request: %v", latency)
+	}
+}
+
+// slowAuthProvider simulates a provider which takes a while to authenticate the requests, for example because it
+// acquires a token or waits for a TOTP code.
+type slowAuthProvider struct {
+	auth.Provider
+	delay time.Duration
+}
+
+func (provider *slowAuthProvider) Authenticate(request *http.Request) error {
+	time.Sleep(provider.delay)
+	return provider.Provider.Authenticate(request)
+}
+
+func TestLatencyExcludesAuthentication(t *testing.T) {
+	healthy := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	provider := &slowAuthProvider{Provider: auth.NewBasicAuthProvider("username", "password"), delay: 300 * time.Millisecond}
+	client := rest.NewMultiplexedRestClient(provider, "test", []string{healthy.URL})
+
+	response, err := client.Invoke(rest.InvocationOptions{
+		Method:   http.MethodGet,
+		Resource: "credits/balance",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = response.Body.Close()
+
+	if latency := client.EndpointHealth()[0].Latency; latency == 0 || latency >= 300*time.Millisecond {
+		t.Fatalf("unexpected latency of the endpoint: %v", latency)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	dropping := newDroppingServer(t)
+	healthy := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client := rest.NewMultiplexedRestClient(auth.NewBasicAuthProvider("username", "password"), "test",
+		[]string{dropping.URL, healthy.URL},
+		rest.WithCircuitBreaker(rest.CircuitBreakerOptions{
+			FailureThreshold: 1,
+			Cooldown:         time.Hour,
+			LatencySmoothing: 0.2,
+		}))
+
+	for i := 0; i < 5; i++ {
+		response, err := client.Invoke(rest.InvocationOptions{
+			Method:   http.MethodGet,
+			Resource: "credits/balance",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = response.Body.Close()
+	}
+
+	health := client.EndpointHealth()
+
+	if health[0].State != rest.CircuitOpen || health[0].ConsecutiveFailures != 1 {
+		t.Fatalf("unexpected health for the failing endpoint: %+v", health[0])
+	}
+
+	if health[1].State != rest.CircuitClosed || health[1].Latency == 0 || len(healthy.Requests()) != 5 {
+		t.Fatalf("unexpected health for the healthy endpoint: %+v", health[1])
+	}
+}
+
+func TestCircuitBreakerDefaults(t *testing.T) {
+	dropping := newDroppingServer(t)
+	healthy := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// The zero fields of the options fall back to the default ones
+
+	client := rest.NewMultiplexedRestClient(auth.NewBasicAuthProvider("username", "password"), "test",
+		[]string{dropping.URL, healthy.URL},
+		rest.WithCircuitBreaker(rest.CircuitBreakerOptions{Cooldown: time.Hour}))
+
+	response, err := client.Invoke(rest.InvocationOptions{
+		Method:   http.MethodGet,
+		Resource: "credits/balance",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = response.Body.Close()
+
+	if health := client.EndpointHealth()[0]; health.State != rest.CircuitClosed || health.ConsecutiveFailures != 1 {
+		t.Fatalf("unexpected health for the failing endpoint: %+v", health)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+)
+
+func TestCompletionCallbackSettings(t *testing.T) {
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, fakeJobJson("job", emailValidation.JobStatus.InProgress))
+	})
+
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+	callbackUrl, _ := url.Parse("https://receiver.internal/callbacks?tenant=42")
+
+	submit := func(options emailValidation.SubmissionOptions) map[string]interface{} {
+		if _, err := validations.SubmitWithOptions(emailValidation.ValidationRequestEntry{InputData: "batman@gmail.com"}, &options); err != nil {
+			t.Fatal(err)
+		}
+
+		requests := server.Requests()
+
+		var request struct {
+			Callback map[string]interface{} `json:"callback"`
+		}
+
+		if err := json.Unmarshal([]byte(requests[len(requests)-1].Body), &request); err != nil {
+			t.Fatal(err)
+		}
+
+		return request.Callback
+	}
+
+	// The bare URL is still supported
+
+	callback := submit(emailValidation.SubmissionOptions{CompletionCallback: *callbackUrl})
+
+	if len(callback) != 1 || callback["url"] != callbackUrl.String() {
+		t.Fatalf("unexpected callback: %v", callback)
+	}
+
+	// The explicit settings take precedence over the bare URL and the additional settings
+
+	callback = submit(emailValidation.SubmissionOptions{
+		CompletionCallback: url.URL{Scheme: "https", Host: "ignored.example.com"},
+		Callback: &emailValidation.CompletionCallback{
+			Url:                             *callbackUrl,
+			Version:                         emailValidation.CallbackVersion.V1_1,
+			SkipServerCertificateValidation: true,
+			AdditionalSettings: map[string]interface{}{
+				"url":          "https://overridden.example.com",
+				"futureOption": "value",
+			},
+		},
+	})
+
+	if len(callback) != 4 || callback["url"] != callbackUrl.String() || callback["version"] != "1.1" ||
+		callback["skipServerCertificateValidation"] != true || callback["futureOption"] != "value" {
+		t.Fatalf("unexpected callback: %v", callback)
+	}
+}
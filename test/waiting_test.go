@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifalia/verifalia-go-sdk/verifalia"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+)
+
+// newProgressingJobServer returns a fake endpoint serving a job which completes after the specified number of polls,
+// reporting its progress in the meantime.
+func newProgressingJobServer(t *testing.T, noOfPolls int32) *recordingServer {
+	var polls int32
+
+	return newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		if atomic.AddInt32(&polls, 1) >= noOfPolls {
+			_, _ = io.WriteString(w, fakeJobJson("job", emailValidation.JobStatus.Completed))
+			return
+		}
+
+		_, _ = io.WriteString(w, strings.Replace(fakeJobJson("job", emailValidation.JobStatus.InProgress),
+			`"noOfEntries": 1`, `"noOfEntries": 1, "progress": { "percentage": 0.5, "estimatedTimeRemaining": "00:00:10" }`, 1))
+	})
+}
+
+func noWait(overview emailValidation.Overview, ctx context.Context) error {
+	return ctx.Err()
+}
+
+func TestWaitingProgress(t *testing.T) {
+	server := newProgressingJobServer(t, 3)
+	validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+	var updates []emailValidation.ProgressUpdate
+	job := &emailValidation.Job{Overview: emailValidation.Overview{Id: "job", Status: emailValidation.JobStatus.InProgress}}
+
+	_, err := validations.WaitForCompletionWithOptions(job, &emailValidation.WaitingOptions{
+		WaitForNextPoll: noWait,
+		ProgressHandler: func(update emailValidation.ProgressUpdate) {
+			updates = append(updates, update)
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 3 {
+		t.Fatalf("unexpected number of updates: %d", len(updates))
+	}
+
+	first, last := updates[0], updates[2]
+
+	if first.Status != emailValidation.JobStatus.InProgress || first.PreviousStatus != emailValidation.JobStatus.InProgress ||
+		first.Progress == nil || first.Progress.Percentage.String() != "0.5" ||
+		first.Progress.EstimatedTimeRemaining == nil || *first.Progress.EstimatedTimeRemaining != 10*time.Second {
+		t.Fatalf("unexpected first update: %+v", first)
+	}
+
+	if last.Status != emailValidation.JobStatus.Completed || last.PreviousStatus != emailValidation.JobStatus.InProgress ||
+		last.Elapsed < first.Elapsed {
+		t.Fatalf("unexpected last update: %+v", last)
+	}
+}
+
+func TestPollingStrategies(t *testing.T) {
+	job := &emailValidation.Job{Overview: emailValidation.Overview{Id: "job", Status: emailValidation.JobStatus.InProgress, NoOfEntries: 1}}
+
+	wait := func(server *recordingServer, options *emailValidation.WaitingOptions) time.Duration {
+		validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+		started := time.Now()
+
+		if _, err := validations.WaitForCompletionWithOptions(job, options); err != nil {
+			t.Fatal(err)
+		}
+
+		return time.Since(started)
+	}
+
+	// The default strategy polls small jobs often
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		_, _ = io.WriteString(w, fakeJobJson("job", emailValidation.JobStatus.Completed))
+	})
+
+	if elapsed := wait(server, nil); elapsed > 2*time.Second {
+		t.Fatalf("the default strategy waited too much for a single-entry job: %v", elapsed)
+	}
+
+	// The ETA is bounded by the specified maximum interval
+
+	server = newProgressingJobServer(t, 3)
+
+	if elapsed := wait(server, &emailValidation.WaitingOptions{WaitForNextPoll: emailValidation.EtaPolling(10*time.Millisecond, 50*time.Millisecond)}); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("unexpected waiting time for the ETA-driven strategy: %v", elapsed)
+	}
+
+	// Long polling leaves the waiting to the server
+
+	server = newProgressingJobServer(t, 2)
+
+	longPolling := emailValidation.LongPolling(0, 10*time.Millisecond)
+	wait(server, &longPolling)
+
+	for _, request := range server.Requests() {
+		if request.Query != "waitTime=20" {
+			t.Fatalf("unexpected long polling request: %+v", request)
+		}
+	}
+
+	// Exponential backoff starts from the initial interval for each waiting process, even for jobs submitted long
+	// ago, and then grows by the specified factor
+
+	backoff := emailValidation.ExponentialBackoffPolling(20*time.Millisecond, time.Second, 2)
+	resumedJob := &emailValidation.Job{Overview: job.Overview}
+	resumedJob.Overview.SubmittedOn = time.Now().Add(-time.Hour)
+
+	for run := 0; run < 2; run++ {
+		var intervals []time.Duration
+		server = newProgressingJobServer(t, 4)
+		validations := emailValidation.Client{RestClient: buildFakeRestClient(server.URL)}
+
+		_, err := validations.WaitForCompletionWithOptions(resumedJob, &emailValidation.WaitingOptions{
+			WaitForNextPoll: func(overview emailValidation.Overview, ctx context.Context) error {
+				started := time.Now()
+				err := backoff(overview, ctx)
+				intervals = append(intervals, time.Since(started))
+
+				return err
+			},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(intervals) != 4 || intervals[0] > 500*time.Millisecond {
+			t.Fatalf("unexpected intervals for the exponential backoff strategy: %v", intervals)
+		}
+
+		for i, interval := range intervals {
+			if expected := 20 * time.Millisecond << i; interval < expected {
+				t.Fatalf("the intervals of the exponential backoff strategy did not grow: %v", intervals)
+			}
+		}
+	}
+}
+
+func TestLongPollingHeldRequests(t *testing.T) {
+	var polls int32
+
+	// The fake endpoint holds each poll for the whole wait time, as the API does while the job is in progress
+
+	server := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request, body string) {
+		waitTime, err := strconv.ParseFloat(r.URL.Query().Get("waitTime"), 64)
+
+		if err != nil {
+			t.Errorf("unexpected long polling request: %v", r.URL)
+		}
+
+		time.Sleep(time.Duration(waitTime * float64(time.Second)))
+
+		if atomic.AddInt32(&polls, 1) < 2 {
+			_, _ = io.WriteString(w, fakeJobJson("job", emailValidation.JobStatus.InProgress))
+			return
+		}
+
+		_, _ = io.WriteString(w, fakeJobJson("job", emailValidation.JobStatus.Completed))
+	})
+
+	// The polls last as much as the request timeout, which is extended by their wait time
+
+	client, err := verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+		verifalia.WithBaseUrls(server.URL, server.URL),
+		verifalia.WithTimeout(300*time.Millisecond))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := &emailValidation.Job{Overview: emailValidation.Overview{Id: "job", Status: emailValidation.JobStatus.InProgress}}
+	options := emailValidation.LongPolling(300*time.Millisecond, 10*time.Millisecond)
+
+	if job, err = client.EmailValidation.WaitForCompletionWithOptions(job, &options); err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Overview.Status != emailValidation.JobStatus.Completed {
+		t.Fatalf("unexpected job status: %v", job.Overview.Status)
+	}
+
+	if requests := server.Requests(); len(requests) != 2 {
+		t.Fatalf("unexpected polls: %+v", requests)
+	}
+
+	for _, health := range client.EndpointHealth() {
+		if health.ConsecutiveFailures != 0 {
+			t.Fatalf("unexpected endpoint failure: %+v", health)
+		}
+	}
+}
@@ -53,7 +53,7 @@ func (provider appKeyAuthProvider) Authenticate(request *http.Request) error {
 }
 
 func (provider appKeyAuthProvider) HandleUnauthorizedRequest() error {
-	return nil
+	return ErrUnrecoverable
 }
 
 func (provider appKeyAuthProvider) BuildClient() *http.Client {
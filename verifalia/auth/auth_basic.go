@@ -54,7 +54,7 @@ func (provider basicAuthProvider) Authenticate(request *http.Request) error {
 }
 
 func (provider basicAuthProvider) HandleUnauthorizedRequest() error {
-	return nil
+	return ErrUnrecoverable
 }
 
 func (provider basicAuthProvider) BuildClient() *http.Client {
@@ -0,0 +1,462 @@
+package auth
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bearer (JWT) authentication
+
+// TotpTokenProvider provides the TOTP (time-based one-time password) code used to complete the multi-factor
+// authentication step, in the event the Verifalia user has multi-factor authentication enabled.
+type TotpTokenProvider func(ctx context.Context) (string, error)
+
+// The maximum number of TOTP codes requested to the TotpTokenProvider before giving up.
+const maxNoOfTotpAttempts = 3
+
+// The access token is refreshed this much time before its actual expiration, to account for clock skews and
+// in-flight requests; the margin is capped to a fraction of the lifetime of the token, so that short-lived tokens are
+// still cached for most of their lifetime.
+const (
+	bearerTokenRefreshMargin         = time.Minute
+	bearerTokenRefreshMarginFraction = 4
+)
+
+type bearerAuthProvider struct {
+	Username          string
+	Password          string
+	BaseUrls          []string
+	TotpTokenProvider TotpTokenProvider
+	Client            *http.Client
+
+	mutex       sync.Mutex
+	accessToken string
+	refreshOn   time.Time
+	flight      *bearerTokenFlight
+}
+
+// bearerTokenFlight is an in-progress acquisition of an access token, shared by all the requests which need one.
+type bearerTokenFlight struct {
+	done        chan struct{}
+	accessToken string
+	refreshOn   time.Time
+	err         error
+
+	// True if the acquisition failed because the context of the request which started it is done.
+	aborted bool
+}
+
+type bearerTokenResponse struct {
+	AccessToken string `json:"accessToken"`
+}
+
+func (provider *bearerAuthProvider) Authenticate(request *http.Request) error {
+	accessToken, err := provider.ensureAccessToken(request.Context())
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	return nil
+}
+
+// HandleUnauthorizedRequest discards the cached access token, so that a new one will be acquired while
+// authenticating the next request.
+func (provider *bearerAuthProvider) HandleUnauthorizedRequest() error {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.accessToken = ""
+	provider.refreshOn = time.Time{}
+
+	return nil
+}
+
+func (provider *bearerAuthProvider) BuildClient() *http.Client {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	return provider.Client
+}
+
+// ConfigureClient makes the provider acquire its tokens through the specified HTTP client and, unless nil, from the
+// specified base URLs.
+func (provider *bearerAuthProvider) ConfigureClient(httpClient *http.Client, baseUrls []string) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.Client = httpClient
+
+	if baseUrls != nil {
+		provider.BaseUrls = baseUrls
+	}
+}
+
+// ensureAccessToken returns the cached access token, if still valid, or acquires a new one. Only one request at a
+// time acquires the token (which may involve asking the user for a TOTP code), while the other ones wait for it for
+// as long as their contexts allow.
+func (provider *bearerAuthProvider) ensureAccessToken(ctx context.Context) (string, error) {
+	for {
+		provider.mutex.Lock()
+
+		if provider.hasValidAccessToken() {
+			accessToken := provider.accessToken
+			provider.mutex.Unlock()
+
+			return accessToken, nil
+		}
+
+		flight := provider.flight
+
+		if flight == nil {
+			flight = &bearerTokenFlight{
+				done: make(chan struct{}),
+			}
+
+			provider.flight = flight
+			provider.mutex.Unlock()
+
+			provider.runFlight(ctx, flight)
+
+			return flight.accessToken, flight.err
+		}
+
+		provider.mutex.Unlock()
+
+		select {
+		case <-flight.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		// An acquisition aborted by the context of another request says nothing about the credentials: try again
+
+		if !flight.aborted {
+			return flight.accessToken, flight.err
+		}
+	}
+}
+
+// hasValidAccessToken returns true if the cached access token can be used; tokens without an expiration time are
+// used until the Verifalia API rejects them. Must be called while holding the mutex.
+func (provider *bearerAuthProvider) hasValidAccessToken() bool {
+	if provider.accessToken == "" {
+		return false
+	}
+
+	return provider.refreshOn.IsZero() || time.Now().Before(provider.refreshOn)
+}
+
+// runFlight acquires a new access token on behalf of the specified flight, caches it and then wakes up the requests
+// waiting for it.
+func (provider *bearerAuthProvider) runFlight(ctx context.Context, flight *bearerTokenFlight) {
+	// Wake up the waiting requests even if the acquisition panics (e.g. because of the TotpTokenProvider): in that
+	// case the flight is marked as aborted, so that the next request acquires the token again
+
+	completed := false
+
+	defer func() {
+		provider.mutex.Lock()
+
+		if !completed {
+			flight.aborted = true
+		} else if flight.err == nil {
+			provider.accessToken = flight.accessToken
+			provider.refreshOn = flight.refreshOn
+		}
+
+		provider.flight = nil
+		provider.mutex.Unlock()
+
+		close(flight.done)
+	}()
+
+	accessToken, refreshOn, err := provider.acquireAccessToken(ctx)
+
+	flight.accessToken = accessToken
+	flight.refreshOn = refreshOn
+	flight.err = err
+	flight.aborted = err != nil && ctx.Err() != nil
+
+	completed = true
+}
+
+// acquireAccessToken exchanges the credentials for a new access token, completing the multi-factor authentication
+// step if needed, and returns it along with the time it should be refreshed at (zero if the token does not expire).
+func (provider *bearerAuthProvider) acquireAccessToken(ctx context.Context) (string, time.Time, error) {
+	accessToken, err := provider.exchangeCredentials(ctx)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims, err := parseJwtClaims(accessToken)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// Multi-factor authentication is required if the token has the verifalia:mfa claim
+
+	if _, ok := claims["verifalia:mfa"]; ok {
+		accessToken, err = provider.verifyTotp(ctx, accessToken)
+
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		if claims, err = parseJwtClaims(accessToken); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+
+	exp, ok := claims["exp"].(float64)
+
+	if !ok {
+		return accessToken, time.Time{}, nil
+	}
+
+	// The lifetime of the token is measured from its issue time, if available, or from now
+
+	expiresOn := time.Unix(int64(exp), 0)
+	issuedOn := time.Now()
+
+	if iat, ok := claims["iat"].(float64); ok {
+		issuedOn = time.Unix(int64(iat), 0)
+	}
+
+	refreshMargin := bearerTokenRefreshMargin
+
+	if lifetimeMargin := expiresOn.Sub(issuedOn) / bearerTokenRefreshMarginFraction; lifetimeMargin < refreshMargin {
+		refreshMargin = max(lifetimeMargin, 0)
+	}
+
+	return accessToken, expiresOn.Add(-refreshMargin), nil
+}
+
+func (provider *bearerAuthProvider) exchangeCredentials(ctx context.Context) (string, error) {
+	if provider.Username == "" {
+		return "", fmt.Errorf("%w: empty username, please specify a valid value before authenticating", ErrAuthenticationFailed)
+	}
+
+	requestData, err := json.Marshal(struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{
+		Username: provider.Username,
+		Password: provider.Password,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return provider.requestToken(ctx, "auth/tokens", "", requestData)
+}
+
+func (provider *bearerAuthProvider) verifyTotp(ctx context.Context, mfaAccessToken string) (string, error) {
+	if provider.TotpTokenProvider == nil {
+		return "", fmt.Errorf("%w: multi-factor authentication is required for this user, please specify a TotpTokenProvider", ErrAuthenticationFailed)
+	}
+
+	var lastErr error
+
+	for idxAttempt := 0; idxAttempt < maxNoOfTotpAttempts; idxAttempt++ {
+		passCode, err := provider.TotpTokenProvider(ctx)
+
+		if err != nil {
+			return "", err
+		}
+
+		requestData, err := json.Marshal(struct {
+			PassCode string `json:"passCode"`
+		}{
+			PassCode: passCode,
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		accessToken, err := provider.requestToken(ctx, "auth/totp/verifications", mfaAccessToken, requestData)
+
+		if err == nil {
+			return accessToken, nil
+		}
+
+		// Only rejected codes are worth asking for another one
+
+		if !errors.Is(err, ErrAuthenticationFailed) {
+			return "", err
+		}
+
+		lastErr = err
+	}
+
+	return "", fmt.Errorf("can't verify the provided TOTP codes: %w", lastErr)
+}
+
+// requestToken posts the specified data to the given token resource, trying each of the configured base URLs
+// until one of them replies.
+func (provider *bearerAuthProvider) requestToken(ctx context.Context, resource string, accessToken string, requestData []byte) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	provider.mutex.Lock()
+	httpClient, baseUrls := provider.Client, provider.BaseUrls
+	provider.mutex.Unlock()
+
+	var lastErr error
+
+	for _, baseUrl := range baseUrls {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s", baseUrl, resource), bytes.NewReader(requestData))
+
+		if err != nil {
+			return "", err
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Accept", "application/json")
+
+		if accessToken != "" {
+			request.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+
+		response, err := httpClient.Do(request)
+
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		responseData, err := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		switch {
+		case response.StatusCode == http.StatusOK:
+			var tokenResponse bearerTokenResponse
+
+			if err := json.Unmarshal(responseData, &tokenResponse); err != nil {
+				return "", err
+			}
+
+			if tokenResponse.AccessToken == "" {
+				return "", errors.New("the Verifalia API returned an empty access token")
+			}
+
+			return tokenResponse.AccessToken, nil
+
+		case response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusUnauthorized ||
+			response.StatusCode == http.StatusForbidden:
+			// Rejected credentials won't be accepted by another endpoint
+
+			return "", fmt.Errorf("%w (HTTP status code: %d)", ErrAuthenticationFailed, response.StatusCode)
+
+		case response.StatusCode >= 400 && response.StatusCode < 500:
+			// Other client errors (e.g. throttled requests) are left to the REST client, which may retry them
+
+			return "", &ResponseError{
+				Method:     request.Method,
+				Url:        request.URL.String(),
+				StatusCode: response.StatusCode,
+				Header:     response.Header,
+				Body:       responseData,
+			}
+		}
+
+		lastErr = fmt.Errorf("unexpected HTTP response: %d", response.StatusCode)
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no base URL configured")
+	}
+
+	return "", fmt.Errorf("can't acquire a bearer token: %w", lastErr)
+}
+
+// parseJwtClaims extracts the claims of the specified JWT, without validating its signature (which is a task
+// for the Verifalia API).
+func parseJwtClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, errors.New("malformed access token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+
+	if err != nil {
+		return nil, fmt.Errorf("malformed access token: %w", err)
+	}
+
+	var claims map[string]interface{}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed access token: %w", err)
+	}
+
+	return claims, nil
+}
+
+// NewBearerAuthProvider creates a Provider which exchanges the specified username and password for a bearer token
+// (JWT), issued by the Verifalia API at the given base URLs: the token is then cached and automatically refreshed
+// before its expiration (tokens without an expiration time are cached until the API rejects them). The
+// totpTokenProvider is required only if the user has multi-factor authentication enabled, and can be nil otherwise.
+func NewBearerAuthProvider(username string, password string, baseUrls []string, totpTokenProvider TotpTokenProvider) Provider {
+	return &bearerAuthProvider{
+		Username:          username,
+		Password:          password,
+		BaseUrls:          baseUrls,
+		TotpTokenProvider: totpTokenProvider,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
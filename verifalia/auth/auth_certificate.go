@@ -49,7 +49,7 @@ func (provider certificateAuthProvider) Authenticate(request *http.Request) erro
 }
 
 func (provider certificateAuthProvider) HandleUnauthorizedRequest() error {
-	return nil
+	return ErrUnrecoverable
 }
 
 func (provider certificateAuthProvider) BuildClient() *http.Client {
@@ -73,3 +73,10 @@ func NewCertificateAuthProvider(certificate *tls.Certificate) Provider {
 		Client:      client,
 	}
 }
+
+// IsCertificateAuthProvider returns true if the specified provider authenticates through a TLS client certificate,
+// and thus requires the client-certificate authentication endpoints of the Verifalia API.
+func IsCertificateAuthProvider(provider Provider) bool {
+	_, ok := provider.(*certificateAuthProvider)
+	return ok
+}
@@ -32,11 +32,87 @@ package auth
  */
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 )
 
+// ErrAuthenticationFailed is matched (through errors.Is) by the errors of the providers which could not authenticate
+// a request because the Verifalia API rejected their credentials, for example while acquiring a bearer token. The
+// REST client does not retry these requests, and rest.ErrAuthenticationFailed is the same error.
+var ErrAuthenticationFailed = errors.New("can't authenticate to Verifalia using the provided credential")
+
+// ErrUnrecoverable is returned by Provider.HandleUnauthorizedRequest when the provider can't do anything to recover
+// from a rejected request, for example because its credentials are fixed.
+var ErrUnrecoverable = errors.New("the authentication provider can't recover from the rejected request")
+
+// ResponseError is returned by the providers which send requests of their own to the Verifalia API, such as the
+// bearer authentication provider while acquiring its tokens, when the API replies with an unexpected HTTP status code
+// which does not mean their credentials have been rejected (for example, 429 for a throttled request). The REST
+// client handles the response as if it was the one of the request being authenticated, thus applying its retry
+// policy and reporting the failure through a rest.APIError.
+type ResponseError struct {
+	// The HTTP method of the request sent by the provider.
+	Method string
+
+	// The URL of the request sent by the provider.
+	Url string
+
+	// The HTTP status code returned by the API.
+	StatusCode int
+
+	// The headers of the response.
+	Header http.Header
+
+	// The body of the response.
+	Body []byte
+}
+
+func (err *ResponseError) Error() string {
+	return fmt.Sprintf("unexpected HTTP response: %d (%s %s)", err.StatusCode, err.Method, err.Url)
+}
+
+// Response returns a new http.Response with the status code, the headers and the body of the response which caused
+// the error; each returned response has a body of its own.
+func (err *ResponseError) Response() *http.Response {
+	response := &http.Response{
+		Status:        fmt.Sprintf("%d %s", err.StatusCode, http.StatusText(err.StatusCode)),
+		StatusCode:    err.StatusCode,
+		Header:        err.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(err.Body)),
+		ContentLength: int64(len(err.Body)),
+	}
+
+	if request, requestErr := http.NewRequest(err.Method, err.Url, nil); requestErr == nil {
+		response.Request = request
+	}
+
+	return response
+}
+
+// Provider authenticates the requests sent to the Verifalia API.
 type Provider interface {
+	// Authenticate adds the authentication data to the specified request.
 	Authenticate(request *http.Request) error
+
+	// HandleUnauthorizedRequest is invoked after the Verifalia API rejects the credentials of a request authenticated
+	// by this provider (HTTP status code 401), allowing it to recover, for example by discarding a cached token or by
+	// reloading rotated credentials; it is not invoked for the requests rejected because the user lacks the needed
+	// permissions (HTTP status code 403). Returning nil makes the REST client retry the request once; returning an
+	// error (such as ErrUnrecoverable) makes it fail immediately.
 	HandleUnauthorizedRequest() error
+
+	// BuildClient creates the HTTP client used to send the requests to the Verifalia API.
 	BuildClient() *http.Client
 }
+
+// ClientConfigurable is implemented by the providers which send requests of their own to the Verifalia API, such as
+// the bearer authentication provider while acquiring its tokens, so that these requests honour the settings of the
+// client which uses the provider. A provider should not be shared among clients with different settings.
+type ClientConfigurable interface {
+	// ConfigureClient sets the HTTP client the provider sends its own requests through and, unless nil, the base URLs
+	// of the Verifalia API; it is invoked once, before the provider authenticates any request.
+	ConfigureClient(httpClient *http.Client, baseUrls []string)
+}
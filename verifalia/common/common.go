@@ -47,17 +47,34 @@ const (
 	Backward
 )
 
+// ListingCursor points to a segment of a listing, along with the direction the listing is walked through.
 type ListingCursor struct {
 	Direction Direction
 	Cursor    string
 }
 
+// AddQueryParams adds to the specified query parameters the ones needed to request the segment pointed to by the
+// cursor from the Verifalia API.
+func (cursor ListingCursor) AddQueryParams(queryParams map[string][]string) {
+	if cursor.Direction == Backward {
+		queryParams["cursor:prev"] = []string{cursor.Cursor}
+	} else {
+		queryParams["cursor"] = []string{cursor.Cursor}
+	}
+}
+
+// ListingMeta contains the metadata of a listing segment.
+type ListingMeta struct {
+	// The cursor of the next segment, if the listing is truncated.
+	Cursor string `json:"cursor"`
+
+	// True if the listing has more segments after this one.
+	IsTruncated bool `json:"isTruncated"`
+}
+
 type ListingSegment[T any] struct {
-	Meta *struct {
-		Cursor      string `json:"cursor"`
-		IsTruncated bool   `json:"isTruncated"`
-	} `json:"meta"`
-	Data *[]T `json:"data"`
+	Meta *ListingMeta `json:"meta"`
+	Data *[]T         `json:"data"`
 }
 
 func TimeSpanStringToDuration(timeSpan string) time.Duration {
@@ -66,6 +83,10 @@ func TimeSpanStringToDuration(timeSpan string) time.Duration {
 
 	match := timeSpanRe.FindStringSubmatch(timeSpan)
 
+	if match == nil {
+		return 0
+	}
+
 	days := 0
 	hours, _ := strconv.Atoi(match[2])
 	minutes, _ := strconv.Atoi(match[3])
@@ -0,0 +1,228 @@
+package common
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"iter"
+)
+
+// SegmentRequest describes the segment of a listing to be fetched by a SegmentFetcher.
+type SegmentRequest struct {
+	// The cursor of the segment to fetch; nil for the first segment of the listing.
+	Cursor *ListingCursor
+
+	// The maximum number of items to fetch, if greater than zero; the Verifalia API may choose to override it if it
+	// is either too small or too big.
+	Limit int
+}
+
+// PaginatorOptions allows to define the options of a Paginator.
+type PaginatorOptions struct {
+	// An optional cursor, obtained through Paginator.Cursor, which allows to resume a previous listing from the
+	// segment it points to; the direction of the cursor is kept for the subsequent segments, allowing to step
+	// backward through the listing.
+	Cursor *ListingCursor
+
+	// The maximum number of items to fetch with each segment, if greater than zero.
+	Limit int
+}
+
+// SegmentFetcher fetches a segment of a listing from the Verifalia API.
+type SegmentFetcher[T any] func(ctx context.Context, request SegmentRequest) (*ListingSegment[T], error)
+
+// Paginator follows the cursors of a listing, fetching its segments one at a time through a SegmentFetcher; it also
+// keeps the cursors of the segments it fetched, allowing to step back to them. A Paginator is not safe for concurrent
+// use by multiple goroutines.
+type Paginator[T any] struct {
+	fetch     SegmentFetcher[T]
+	cursor    *ListingCursor
+	direction Direction
+	limit     int
+	started   bool
+
+	// The cursor the current segment has been fetched with (nil for the first segment of the listing), along with the
+	// ones of the segments fetched before it, in the order they have been fetched.
+	segmentCursor   *ListingCursor
+	previousCursors []*ListingCursor
+}
+
+// NewPaginator initializes a new Paginator which fetches the segments of a listing through the specified function.
+func NewPaginator[T any](fetch SegmentFetcher[T]) *Paginator[T] {
+	return NewPaginatorWithOptions(fetch, PaginatorOptions{})
+}
+
+// NewPaginatorWithOptions initializes a new Paginator which fetches the segments of a listing through the specified
+// function, according to the specified options.
+func NewPaginatorWithOptions[T any](fetch SegmentFetcher[T], options PaginatorOptions) *Paginator[T] {
+	paginator := &Paginator[T]{
+		fetch: fetch,
+		limit: options.Limit,
+	}
+
+	if options.Cursor != nil && options.Cursor.Cursor != "" {
+		cursor := *options.Cursor
+		paginator.cursor = &cursor
+		paginator.direction = cursor.Direction
+	}
+
+	return paginator
+}
+
+// Cursor returns the cursor of the next segment of the listing, which can be saved as a checkpoint and passed through
+// PaginatorOptions.Cursor to resume the listing later; it is nil if the listing has not started yet (unless it is
+// resumed from a cursor) or if there are no more segments to fetch.
+func (paginator *Paginator[T]) Cursor() *ListingCursor {
+	return copyCursor(paginator.cursor)
+}
+
+// SegmentCursor returns the cursor of the segment fetched last, which can be passed through PaginatorOptions.Cursor to
+// resume the listing later from that same segment; it is nil if the segment is the first one of the listing (or if
+// the listing has not started yet), as resuming the listing without a cursor starts it from its first segment.
+func (paginator *Paginator[T]) SegmentCursor() *ListingCursor {
+	return copyCursor(paginator.segmentCursor)
+}
+
+// HasMore returns true if the listing has more segments to fetch.
+func (paginator *Paginator[T]) HasMore() bool {
+	return !paginator.started || paginator.cursor != nil
+}
+
+// HasPrevious returns true if the paginator fetched other segments before the one fetched last, which can be fetched
+// again through PreviousSegment.
+func (paginator *Paginator[T]) HasPrevious() bool {
+	return len(paginator.previousCursors) > 0
+}
+
+// NextSegment fetches the next segment of the listing and returns its items; it returns nil once the listing has no
+// more segments to fetch. Should fetching the segment fail, the paginator does not advance, so that the same segment
+// can be requested again.
+func (paginator *Paginator[T]) NextSegment(ctx context.Context) ([]T, error) {
+	if !paginator.HasMore() {
+		return nil, nil
+	}
+
+	cursor := paginator.cursor
+	items, err := paginator.fetchSegment(ctx, cursor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if paginator.started {
+		paginator.previousCursors = append(paginator.previousCursors, paginator.segmentCursor)
+	}
+
+	paginator.started = true
+	paginator.segmentCursor = cursor
+
+	return items, nil
+}
+
+// PreviousSegment steps back to the segment fetched before the one fetched last, fetching it again, and returns its
+// items; the subsequent segments can then be fetched again through NextSegment. It returns nil if the paginator did
+// not fetch any segment before the one fetched last (see HasPrevious): in particular, the segments which precede the
+// cursor a listing is resumed from can't be reached this way, while they can be walked through by resuming the listing
+// from a cursor with the opposite direction. Should fetching the segment fail, the paginator does not step back.
+func (paginator *Paginator[T]) PreviousSegment(ctx context.Context) ([]T, error) {
+	if !paginator.HasPrevious() {
+		return nil, nil
+	}
+
+	cursor := paginator.previousCursors[len(paginator.previousCursors)-1]
+	items, err := paginator.fetchSegment(ctx, cursor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	paginator.previousCursors = paginator.previousCursors[:len(paginator.previousCursors)-1]
+	paginator.segmentCursor = cursor
+
+	return items, nil
+}
+
+// fetchSegment fetches the segment pointed to by the specified cursor and, upon success, makes the paginator point to
+// the segment which follows it.
+func (paginator *Paginator[T]) fetchSegment(ctx context.Context, cursor *ListingCursor) ([]T, error) {
+	segment, err := paginator.fetch(ctx, SegmentRequest{Cursor: cursor, Limit: paginator.limit})
+
+	if err != nil {
+		return nil, err
+	}
+
+	paginator.cursor = nil
+
+	if segment.Meta != nil && segment.Meta.IsTruncated && segment.Meta.Cursor != "" {
+		paginator.cursor = &ListingCursor{
+			Direction: paginator.direction,
+			Cursor:    segment.Meta.Cursor,
+		}
+	}
+
+	if segment.Data == nil {
+		return []T{}, nil
+	}
+
+	return *segment.Data, nil
+}
+
+// All returns an iterator over the items of the remaining segments of the listing, for use with a range loop; should
+// fetching a segment fail, the iterator yields the error and stops.
+func (paginator *Paginator[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for paginator.HasMore() {
+			items, err := paginator.NextSegment(ctx)
+
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func copyCursor(cursor *ListingCursor) *ListingCursor {
+	if cursor == nil {
+		return nil
+	}
+
+	result := *cursor
+	return &result
+}
@@ -34,13 +34,13 @@ package credit
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/ericlagergren/decimal"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
 	"io/ioutil"
 	"net/http"
 )
 
+// Client allows to manage the credits of a Verifalia account; it is safe for concurrent use by multiple goroutines.
 type Client struct {
 	RestClient rest.Client
 }
@@ -70,6 +70,8 @@ func (client *Client) getBalance(ctx context.Context) (*Balance, error) {
 		return nil, err
 	}
 
+	defer response.Body.Close()
+
 	if response.StatusCode == http.StatusOK {
 		responseData, err := ioutil.ReadAll(response.Body)
 
@@ -86,5 +88,5 @@ func (client *Client) getBalance(ctx context.Context) (*Balance, error) {
 		return &balance, nil
 	}
 
-	return nil, fmt.Errorf("unexpected HTTP response: %d", response.StatusCode)
+	return nil, rest.NewAPIError(response)
 }
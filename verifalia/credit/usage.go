@@ -0,0 +1,172 @@
+package credit
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ericlagergren/decimal"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/common"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+	"io/ioutil"
+	"iter"
+	"net/http"
+	"time"
+)
+
+// The format of the dates used by the daily usage API.
+const dailyUsageDateFormat = "2006-01-02"
+
+// DailyUsageOptions allows to define the date range of the credits daily usage listing.
+type DailyUsageOptions struct {
+	// If not zero, only the usage of the days on or after this date is returned; the time of the day is ignored.
+	Since time.Time
+
+	// If not zero, only the usage of the days on or before this date is returned; the time of the day is ignored.
+	Until time.Time
+}
+
+// DailyUsage represents the credits consumed by a Verifalia account in a single day.
+type DailyUsage struct {
+	// The day this usage refers to.
+	Date time.Time
+
+	// The number of credit packs consumed in the day.
+	CreditPacks decimal.Big
+
+	// The number of free daily credits consumed in the day.
+	FreeCredits decimal.Big
+}
+
+type dailyUsage struct {
+	Date        string      `json:"date"`
+	CreditPacks decimal.Big `json:"creditPacks"`
+	FreeCredits decimal.Big `json:"freeCredits"`
+}
+
+// ListDailyUsage returns an iterator over the credits consumed by the Verifalia account day by day, within the
+// specified date range, for use with a range loop, for example:
+//  for usage, err := range client.Credit.ListDailyUsage(ctx, credit.DailyUsageOptions{Since: lastMonth}) {
+//      if err != nil {
+//          panic(err)
+//      }
+//
+//      fmt.Printf("%v: %v credit packs, %v free credits\n", usage.Date, &usage.CreditPacks, &usage.FreeCredits)
+//  }
+// The segments of the listing are requested only while iterating, thus breaking out of the loop does not leak any
+// resource.
+func (client *Client) ListDailyUsage(ctx context.Context, options DailyUsageOptions) iter.Seq2[DailyUsage, error] {
+	return func(yield func(DailyUsage, error) bool) {
+		common.NewPaginator(func(ctx context.Context, request common.SegmentRequest) (*common.ListingSegment[DailyUsage], error) {
+			return client.listDailyUsageSegment(ctx, options, request)
+		}).All(ctx)(yield)
+	}
+}
+
+func (client *Client) listDailyUsageSegment(ctx context.Context, options DailyUsageOptions, request common.SegmentRequest) (*common.ListingSegment[DailyUsage], error) {
+	queryParams := make(map[string][]string)
+
+	if !options.Since.IsZero() {
+		queryParams["date:since"] = []string{options.Since.Format(dailyUsageDateFormat)}
+	}
+
+	if !options.Until.IsZero() {
+		queryParams["date:until"] = []string{options.Until.Format(dailyUsageDateFormat)}
+	}
+
+	if request.Cursor != nil {
+		request.Cursor.AddQueryParams(queryParams)
+	}
+
+	response, err := client.RestClient.Invoke(rest.InvocationOptions{
+		Method:      http.MethodGet,
+		Resource:    "credits/daily-usage",
+		QueryParams: queryParams,
+		Context:     ctx,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, rest.NewAPIError(response)
+	}
+
+	responseData, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rawSegment common.ListingSegment[dailyUsage]
+
+	if err := json.Unmarshal(responseData, &rawSegment); err != nil {
+		return nil, err
+	}
+
+	segment := &common.ListingSegment[DailyUsage]{
+		Meta: rawSegment.Meta,
+	}
+
+	if rawSegment.Data != nil {
+		data := make([]DailyUsage, 0, len(*rawSegment.Data))
+
+		for _, rawUsage := range *rawSegment.Data {
+			date, err := parseDailyUsageDate(rawUsage.Date)
+
+			if err != nil {
+				return nil, err
+			}
+
+			data = append(data, DailyUsage{
+				Date:        date,
+				CreditPacks: rawUsage.CreditPacks,
+				FreeCredits: rawUsage.FreeCredits,
+			})
+		}
+
+		segment.Data = &data
+	}
+
+	return segment, nil
+}
+
+func parseDailyUsageDate(value string) (time.Time, error) {
+	if date, err := time.Parse(dailyUsageDateFormat, value); err == nil {
+		return date, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,333 @@
+package credit
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"errors"
+	"github.com/ericlagergren/decimal"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/common"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The default interval between two subsequent readings of the credits balance.
+const defaultWatcherInterval = 5 * time.Minute
+
+// The minimum delay between two subsequent readings of the credits balance, whatever the interval and the time of
+// the reset of the free daily credits.
+const minWatcherDelay = 5 * time.Second
+
+// Clock provides the current time and the timers to a Watcher; it allows to replace the system clock in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel which receives the current time once the specified duration elapses.
+	After(duration time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+// WatcherEventType provides enumerated-like values for the types of the events raised by a Watcher.
+var WatcherEventType = struct {
+	// The total of the credit packs and of the free credits dropped below one of the configured thresholds.
+	LowBalance string
+
+	// The free daily credits have been reset.
+	FreeCreditsReset string
+
+	// The credits balance could not be read.
+	Error string
+}{
+	LowBalance:       "LowBalance",
+	FreeCreditsReset: "FreeCreditsReset",
+	Error:            "Error",
+}
+
+// WatcherEvent represents an event raised by a Watcher.
+type WatcherEvent struct {
+	// The type of the event. The WatcherEventType enum-like object contains the supported values, for example:
+	// WatcherEventType.LowBalance
+	Type string
+
+	// The credits balance read by the watcher; nil for the events of type WatcherEventType.Error.
+	Balance *Balance
+
+	// The total of the credit packs and of the free credits of the balance.
+	Total *decimal.Big
+
+	// The threshold the total dropped below, for the events of type WatcherEventType.LowBalance.
+	Threshold *decimal.Big
+
+	// The error occurred while reading the balance, for the events of type WatcherEventType.Error.
+	Err error
+
+	// The time the event has been raised, according to the Clock of the watcher.
+	Time time.Time
+}
+
+// WatcherOptions allows to define the options of a Watcher.
+type WatcherOptions struct {
+	// The interval between two subsequent readings of the credits balance; defaults to 5 minutes. Regardless of this
+	// interval, the balance is also read as soon as the free daily credits are due to be reset, but never more often
+	// than every 5 seconds.
+	Interval time.Duration
+
+	// The thresholds of the total of the credit packs and of the free credits: a WatcherEventType.LowBalance event is
+	// raised once the total drops below each of them, and again only after the total gets back above it (for example,
+	// after purchasing more credit packs).
+	Thresholds []*decimal.Big
+
+	// The function which receives the events raised by the watcher; it is invoked from the goroutine of the watcher,
+	// which does not read the balance again until the function returns. The function can stop the watcher.
+	OnEvent func(event WatcherEvent)
+
+	// An optional replacement of the system clock, mostly useful for testing purposes.
+	Clock Clock
+}
+
+// Watcher periodically reads the credits balance of a Verifalia account, keeps the last reading and raises events when
+// the balance drops below the configured thresholds or when the free daily credits are reset. A Watcher is safe for
+// concurrent use by multiple goroutines.
+type Watcher struct {
+	client  *Client
+	options WatcherOptions
+	clock   Clock
+
+	mutex       sync.Mutex
+	current     *watcherRun
+	lastBalance *Balance
+	lastReadOn  time.Time
+	resetOn     time.Time
+	belowOf     map[*decimal.Big]bool
+}
+
+// watcherRun is a single run of the goroutine of a Watcher, from Start to Stop.
+type watcherRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Set (atomically) while the OnEvent function is running on the goroutine.
+	raising int32
+}
+
+// NewWatcher initializes a new Watcher which reads the credits balance through the specified client; call Start to
+// start watching.
+func NewWatcher(client *Client, options WatcherOptions) *Watcher {
+	clock := options.Clock
+
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = defaultWatcherInterval
+	}
+
+	return &Watcher{
+		client:  client,
+		options: options,
+		clock:   clock,
+		belowOf: make(map[*decimal.Big]bool),
+	}
+}
+
+// Start starts watching the credits balance in a new goroutine, reading it right away; the watcher stops once either
+// Stop is called or the specified context is done.
+func (watcher *Watcher) Start(ctx context.Context) error {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	if watcher.current != nil {
+		return errors.New("the watcher is already started")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	run := &watcherRun{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	watcher.current = run
+
+	go func() {
+		defer close(run.done)
+		watcher.run(ctx, run)
+	}()
+
+	return nil
+}
+
+// Stop stops watching the credits balance and waits for the goroutine of the watcher to exit; the watcher can then be
+// started again. Stop can be called from within the OnEvent function as well (for example, upon a low balance): in
+// that case, as with any call made while the OnEvent function is running, Stop does not wait for the function to
+// return, and no further events are raised.
+func (watcher *Watcher) Stop() {
+	watcher.mutex.Lock()
+	run := watcher.current
+	watcher.current = nil
+	watcher.mutex.Unlock()
+
+	if run == nil {
+		return
+	}
+
+	run.cancel()
+
+	// The goroutine can't exit while running the OnEvent function, which may be the caller of Stop
+
+	if atomic.LoadInt32(&run.raising) == 0 {
+		<-run.done
+	}
+}
+
+// LastBalance returns the last credits balance read by the watcher, along with the time it has been read; the balance
+// is nil if the watcher has not read it yet.
+func (watcher *Watcher) LastBalance() (*Balance, time.Time) {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	return watcher.lastBalance, watcher.lastReadOn
+}
+
+func (watcher *Watcher) run(ctx context.Context, run *watcherRun) {
+	for {
+		watcher.poll(ctx, run)
+
+		select {
+		case <-watcher.clock.After(watcher.nextPollIn()):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nextPollIn returns the time until the next reading of the balance, which happens earlier than the configured
+// interval if the free daily credits are due to be reset in the meantime.
+func (watcher *Watcher) nextPollIn() time.Duration {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	interval := watcher.options.Interval
+
+	if !watcher.resetOn.IsZero() {
+		if untilReset := watcher.resetOn.Sub(watcher.clock.Now()); untilReset < interval {
+			interval = untilReset
+		}
+	}
+
+	if interval < minWatcherDelay {
+		interval = minWatcherDelay
+	}
+
+	return interval
+}
+
+func (watcher *Watcher) poll(ctx context.Context, run *watcherRun) {
+	balance, err := watcher.client.GetBalanceWithContext(ctx)
+	now := watcher.clock.Now()
+
+	if err != nil {
+		watcher.raise(ctx, run, WatcherEvent{Type: WatcherEventType.Error, Err: err, Time: now})
+
+		return
+	}
+
+	total := new(decimal.Big).Copy(&balance.CreditPacks)
+
+	if balance.FreeCredits != nil {
+		total.Add(total, balance.FreeCredits)
+	}
+
+	var events []WatcherEvent
+
+	watcher.mutex.Lock()
+
+	// Free credits reset
+
+	if !watcher.resetOn.IsZero() && !now.Before(watcher.resetOn) {
+		events = append(events, WatcherEvent{Type: WatcherEventType.FreeCreditsReset, Balance: balance, Total: total, Time: now})
+	}
+
+	// A reset which is due right now (or already passed) is awaited by the regular readings, as scheduling it would
+	// raise the same event over and over
+
+	watcher.resetOn = time.Time{}
+
+	if balance.FreeCreditsResetIn != nil {
+		if resetIn := common.TimeSpanStringToDuration(*balance.FreeCreditsResetIn); resetIn > 0 {
+			watcher.resetOn = now.Add(resetIn)
+		}
+	}
+
+	// Thresholds, which are raised once crossed and re-armed once the total gets back above them
+
+	for _, threshold := range watcher.options.Thresholds {
+		below := total.Cmp(threshold) < 0
+
+		if below && !watcher.belowOf[threshold] {
+			events = append(events, WatcherEvent{Type: WatcherEventType.LowBalance, Balance: balance, Total: total, Threshold: threshold, Time: now})
+		}
+
+		watcher.belowOf[threshold] = below
+	}
+
+	watcher.lastBalance = balance
+	watcher.lastReadOn = now
+	watcher.mutex.Unlock()
+
+	for _, event := range events {
+		watcher.raise(ctx, run, event)
+	}
+}
+
+// raise passes the specified event to the OnEvent function, unless the watcher has been stopped in the meantime.
+func (watcher *Watcher) raise(ctx context.Context, run *watcherRun, event WatcherEvent) {
+	if watcher.options.OnEvent == nil || ctx.Err() != nil {
+		return
+	}
+
+	atomic.StoreInt32(&run.raising, 1)
+	defer atomic.StoreInt32(&run.raising, 0)
+
+	watcher.options.OnEvent(event)
+}
@@ -0,0 +1,136 @@
+package callback
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"sync"
+	"time"
+)
+
+// Dispatcher is an emailValidation.CompletionNotifier fed by the completion callbacks, which allows to resume the
+// goroutines waiting for the completion of the jobs as soon as Verifalia notifies it, for example:
+//  dispatcher := callback.NewDispatcher()
+//  http.Handle("/callbacks", callback.NewHandler(dispatcher.HandleEvent, nil))
+//
+//  job, err := client.EmailValidation.WaitForCompletionWithOptions(job, &emailValidation.WaitingOptions{
+//      CompletionNotifier: dispatcher,
+//  })
+// The notifications received before the related subscriptions are remembered for a while, as a job may complete
+// before its submitter starts waiting for it. A Dispatcher is safe for concurrent use by multiple goroutines.
+type Dispatcher struct {
+	mutex       sync.Mutex
+	subscribers map[string]map[*subscription]struct{}
+	completed   map[string]time.Time
+	retention   time.Duration
+}
+
+type subscription struct {
+	notification chan struct{}
+}
+
+// NewDispatcher initializes a new Dispatcher, which remembers the received notifications for one hour.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		subscribers: make(map[string]map[*subscription]struct{}),
+		completed:   make(map[string]time.Time),
+		retention:   time.Hour,
+	}
+}
+
+// Subscribe returns a channel which is closed once the completion of the specified job is notified, along with a
+// function which releases the subscription.
+func (dispatcher *Dispatcher) Subscribe(jobId string) (<-chan struct{}, func()) {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	subscriber := &subscription{notification: make(chan struct{})}
+
+	if _, ok := dispatcher.completed[jobId]; ok {
+		close(subscriber.notification)
+		return subscriber.notification, func() {}
+	}
+
+	if dispatcher.subscribers[jobId] == nil {
+		dispatcher.subscribers[jobId] = make(map[*subscription]struct{})
+	}
+
+	dispatcher.subscribers[jobId][subscriber] = struct{}{}
+
+	return subscriber.notification, func() {
+		dispatcher.mutex.Lock()
+		defer dispatcher.mutex.Unlock()
+
+		if subscribers, ok := dispatcher.subscribers[jobId]; ok {
+			delete(subscribers, subscriber)
+
+			if len(subscribers) == 0 {
+				delete(dispatcher.subscribers, jobId)
+			}
+		}
+	}
+}
+
+// Notify notifies the completion of the specified job to its subscribers.
+func (dispatcher *Dispatcher) Notify(jobId string) {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	for subscriber := range dispatcher.subscribers[jobId] {
+		close(subscriber.notification)
+	}
+
+	delete(dispatcher.subscribers, jobId)
+
+	// Remember the notification for the late subscribers, forgetting the old ones
+
+	now := time.Now()
+
+	for completedJobId, completedOn := range dispatcher.completed {
+		if now.Sub(completedOn) > dispatcher.retention {
+			delete(dispatcher.completed, completedJobId)
+		}
+	}
+
+	dispatcher.completed[jobId] = now
+}
+
+// HandleEvent notifies the completion of the job the specified event refers to; it is meant to be passed to
+// NewHandler, either directly or from another EventHandlerFunc.
+func (dispatcher *Dispatcher) HandleEvent(ctx context.Context, event Event) error {
+	if event.Type == EventType.EmailValidationCompleted || event.Status == emailValidation.JobStatus.Completed {
+		dispatcher.Notify(event.JobId)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package callback
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+)
+
+// EventType provides enumerated-like values for the types of the events notified by Verifalia through the completion
+// callbacks.
+var EventType = struct {
+	// An email validation job has been completed.
+	EmailValidationCompleted string
+}{
+	EmailValidationCompleted: "email-validation.completed",
+}
+
+// Event represents a notification received from Verifalia through a completion callback.
+type Event struct {
+	// The type of the event. The EventType enum-like object contains the supported values, for example:
+	// EventType.EmailValidationCompleted
+	Type string
+
+	// The unique identifier of the email validation job the event refers to.
+	JobId string
+
+	// The eventual user-defined name of the email validation job.
+	Name string
+
+	// The processing status of the email validation job. The emailValidation.JobStatus enum-like object contains the
+	// supported values, for example: emailValidation.JobStatus.Completed
+	Status string
+
+	// The overview of the email validation job, filled with the details available in the callback payload (which
+	// depend on the version of the callback schema) or, if the job is fetched by the Handler, with the ones returned by
+	// the Verifalia API.
+	Overview emailValidation.Overview
+
+	// The whole email validation job, fetched through the Verifalia API; available only if the Handler is configured
+	// to fetch the entries of the jobs (see HandlerOptions.FetchEntries).
+	Job *emailValidation.Job
+}
+
+type rawEvent struct {
+	Event *struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	} `json:"event"`
+}
+
+// ParseEvent decodes the payload of a completion callback sent by Verifalia.
+func ParseEvent(payload []byte) (*Event, error) {
+	var raw rawEvent
+
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+
+	if raw.Event == nil || len(raw.Event.Data) == 0 {
+		return nil, errors.New("the callback payload does not contain any event data")
+	}
+
+	overview, err := emailValidation.UnmarshalOverview(raw.Event.Data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if overview.Id == "" {
+		return nil, errors.New("the callback payload does not contain the job id")
+	}
+
+	// Older versions of the callback schema only include the id of the completed job
+
+	if overview.Status == "" && raw.Event.Type == EventType.EmailValidationCompleted {
+		overview.Status = emailValidation.JobStatus.Completed
+	}
+
+	return &Event{
+		Type:     raw.Event.Type,
+		JobId:    overview.Id,
+		Name:     overview.Name,
+		Status:   overview.Status,
+		Overview: *overview,
+	}, nil
+}
@@ -0,0 +1,245 @@
+package callback
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"errors"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The maximum size of a callback payload accepted by the Handler.
+const maxPayloadSize = 1 << 20
+
+// EventHandlerFunc processes an event notified by Verifalia. Returning an error makes the Handler reply with an HTTP
+// 500 status code, so that Verifalia delivers the event again later.
+type EventHandlerFunc func(ctx context.Context, event Event) error
+
+// HandlerOptions allows to define the options of a Handler.
+type HandlerOptions struct {
+	// If set, the Handler fetches the overview of the email validation job through this client before dispatching the
+	// event, filling Event.Overview with the details returned by the Verifalia API. The events of the jobs which no
+	// longer exist (because they have been deleted or are expired) are acknowledged without being dispatched.
+	Client *emailValidation.Client
+
+	// If true (and Client is set), the Handler fetches the whole email validation job, including all of its entries,
+	// making it available through Event.Job. Beware that the entries are downloaded one segment at a time while the
+	// callback request is pending: for large jobs this may take longer than Verifalia waits for the callback to
+	// complete, in which case the event is delivered again. Consider fetching the entries outside of the event
+	// function, for example through a background worker, instead.
+	FetchEntries bool
+
+	// How long the Handler remembers the events it has already processed, in order to ignore their duplicate
+	// deliveries; defaults to 24 hours.
+	DeduplicationWindow time.Duration
+}
+
+// Handler is an http.Handler which receives the completion callbacks sent by Verifalia, decodes them into typed events
+// and dispatches them to a user function. Duplicate deliveries of an already processed event are acknowledged without
+// being dispatched again; a Handler is safe for concurrent use by multiple goroutines.
+type Handler struct {
+	onEvent             EventHandlerFunc
+	client              *emailValidation.Client
+	fetchEntries        bool
+	deduplicationWindow time.Duration
+
+	mutex     sync.Mutex
+	inFlight  map[string]bool
+	processed map[string]time.Time
+}
+
+// NewHandler initializes a new Handler which dispatches the received events to the specified function; options can
+// be nil.
+func NewHandler(onEvent EventHandlerFunc, options *HandlerOptions) *Handler {
+	handler := &Handler{
+		onEvent:             onEvent,
+		deduplicationWindow: 24 * time.Hour,
+		inFlight:            make(map[string]bool),
+		processed:           make(map[string]time.Time),
+	}
+
+	if options != nil {
+		handler.client = options.Client
+		handler.fetchEntries = options.FetchEntries
+
+		if options.DeduplicationWindow > 0 {
+			handler.deduplicationWindow = options.DeduplicationWindow
+		}
+	}
+
+	return handler
+}
+
+// ServeHTTP handles a completion callback request sent by Verifalia.
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+
+	if err != nil {
+		http.Error(w, "can't read the callback payload", http.StatusBadRequest)
+		return
+	}
+
+	event, err := ParseEvent(payload)
+
+	if err != nil {
+		http.Error(w, "invalid callback payload", http.StatusBadRequest)
+		return
+	}
+
+	// Ignore the duplicate deliveries of the events already processed, while making Verifalia retry later those
+	// of the events being processed right now, as their processing may still fail
+
+	key := event.Type + "/" + event.JobId
+
+	switch handler.acquire(key) {
+	case alreadyProcessed:
+		w.WriteHeader(http.StatusOK)
+		return
+	case beingProcessed:
+		http.Error(w, "the event is being processed", http.StatusConflict)
+		return
+	}
+
+	// Release the event even if its processing panics, so that its next delivery is processed again
+
+	succeeded := false
+	defer func() {
+		handler.release(key, succeeded)
+	}()
+
+	if err := handler.dispatch(r.Context(), event); err != nil {
+		http.Error(w, "can't process the event", http.StatusInternalServerError)
+		return
+	}
+
+	succeeded = true
+	w.WriteHeader(http.StatusOK)
+}
+
+func (handler *Handler) dispatch(ctx context.Context, event *Event) error {
+	if handler.client != nil {
+		if err := handler.fetch(ctx, event); err != nil {
+			// Verifalia would deliver the events of the jobs which no longer exist over and over again: acknowledge them
+
+			if errors.Is(err, rest.ErrNotFound) || errors.Is(err, rest.ErrGone) {
+				return nil
+			}
+
+			return err
+		}
+
+		event.Name = event.Overview.Name
+		event.Status = event.Overview.Status
+	}
+
+	return handler.onEvent(ctx, *event)
+}
+
+// fetch fills the event with the overview of its job or, if configured to do so, with the whole job.
+func (handler *Handler) fetch(ctx context.Context, event *Event) error {
+	if handler.fetchEntries {
+		job, err := handler.client.GetWithContext(ctx, event.JobId)
+
+		if err != nil {
+			return err
+		}
+
+		event.Job = job
+		event.Overview = job.Overview
+		return nil
+	}
+
+	overview, err := handler.client.GetOverviewWithContext(ctx, event.JobId)
+
+	if err != nil {
+		return err
+	}
+
+	event.Overview = *overview
+	return nil
+}
+
+type acquisitionResult int
+
+const (
+	acquired acquisitionResult = iota
+	beingProcessed
+	alreadyProcessed
+)
+
+func (handler *Handler) acquire(key string) acquisitionResult {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	// Forget the events processed outside of the deduplication window
+
+	now := time.Now()
+
+	for processedKey, processedOn := range handler.processed {
+		if now.Sub(processedOn) > handler.deduplicationWindow {
+			delete(handler.processed, processedKey)
+		}
+	}
+
+	if _, ok := handler.processed[key]; ok {
+		return alreadyProcessed
+	}
+
+	if handler.inFlight[key] {
+		return beingProcessed
+	}
+
+	handler.inFlight[key] = true
+	return acquired
+}
+
+func (handler *Handler) release(key string, succeeded bool) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	delete(handler.inFlight, key)
+
+	if succeeded {
+		handler.processed[key] = time.Now()
+	}
+}
@@ -0,0 +1,309 @@
+package callback
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/emailValidation"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenParameter is the name of the query string parameter which carries the token of a signed callback URL.
+const TokenParameter = "verifalia-token"
+
+// The minimum length of the key used to sign the callback URLs.
+const minKeyLength = 32
+
+var (
+	// ErrMissingToken is returned while verifying a callback URL without a token.
+	ErrMissingToken = errors.New("the callback URL is not signed")
+
+	// ErrInvalidToken is returned while verifying a callback URL whose token is malformed or does not match the URL,
+	// for example because either of them has been tampered with.
+	ErrInvalidToken = errors.New("the callback URL has an invalid signature")
+
+	// ErrExpiredToken is returned while verifying a callback URL whose token is expired.
+	ErrExpiredToken = errors.New("the callback URL is expired")
+)
+
+// Signer builds completion callback URLs carrying an HMAC-signed token, which encodes a correlation id (for example,
+// the name of the job) and an expiration time, and verifies the incoming callbacks against it. A Signer is safe for
+// concurrent use by multiple goroutines.
+type Signer struct {
+	key []byte
+
+	mutex    sync.Mutex
+	inFlight map[string]bool
+	consumed map[string]time.Time
+}
+
+type token struct {
+	CorrelationId string `json:"c"`
+	ExpiresOn     int64  `json:"e"`
+	Nonce         string `json:"n"`
+}
+
+type correlationIdKey struct{}
+
+// NewSigner initializes a new Signer with the specified secret key, which must be at least 32 bytes long; to
+// verify the callbacks, the key must be the same one used to sign their URLs.
+func NewSigner(key []byte) (*Signer, error) {
+	if len(key) < minKeyLength {
+		return nil, errors.New("the signing key must be at least 32 bytes long")
+	}
+
+	return &Signer{
+		key:      append([]byte(nil), key...),
+		inFlight: make(map[string]bool),
+		consumed: make(map[string]time.Time),
+	}, nil
+}
+
+// SignUrl returns a copy of the specified callback URL with a signed token, which encodes the specified correlation
+// id and expires after the specified validity period; as Verifalia invokes the callback once the job completes, the
+// validity period should cover the whole expected processing time of the job.
+func (signer *Signer) SignUrl(callbackUrl url.URL, correlationId string, validity time.Duration) (*url.URL, error) {
+	nonce := make([]byte, 16)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(token{
+		CorrelationId: correlationId,
+		ExpiresOn:     time.Now().Add(validity).UnixMilli(),
+		Nonce:         base64.RawURLEncoding.EncodeToString(nonce),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	query := callbackUrl.Query()
+	query.Del(TokenParameter)
+
+	signature := signer.sign(callbackUrl.Path, query, encodedPayload)
+	query.Set(TokenParameter, encodedPayload+"."+signature)
+
+	result := callbackUrl
+	result.RawQuery = query.Encode()
+
+	return &result, nil
+}
+
+// NewCompletionCallback returns a completion callback whose URL is the specified one, signed through SignUrl.
+func (signer *Signer) NewCompletionCallback(callbackUrl url.URL, correlationId string, validity time.Duration) (*emailValidation.CompletionCallback, error) {
+	signedUrl, err := signer.SignUrl(callbackUrl, correlationId, validity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &emailValidation.CompletionCallback{Url: *signedUrl}, nil
+}
+
+// Verify checks the token of the specified callback URL and returns the correlation id it encodes; it does not
+// check whether the URL has already been used, which is a task of the middleware returned by Middleware.
+func (signer *Signer) Verify(callbackUrl *url.URL) (string, error) {
+	parsedToken, err := signer.verify(callbackUrl)
+
+	if err != nil {
+		return "", err
+	}
+
+	return parsedToken.CorrelationId, nil
+}
+
+func (signer *Signer) verify(callbackUrl *url.URL) (*token, error) {
+	query := callbackUrl.Query()
+	rawToken := query.Get(TokenParameter)
+
+	if rawToken == "" {
+		return nil, ErrMissingToken
+	}
+
+	query.Del(TokenParameter)
+
+	encodedPayload, signature, found := strings.Cut(rawToken, ".")
+
+	if !found || !hmac.Equal([]byte(signature), []byte(signer.sign(callbackUrl.Path, query, encodedPayload))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var parsedToken token
+
+	if err := json.Unmarshal(payload, &parsedToken); err != nil || parsedToken.Nonce == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().After(time.UnixMilli(parsedToken.ExpiresOn)) {
+		return nil, ErrExpiredToken
+	}
+
+	return &parsedToken, nil
+}
+
+// sign returns the signature of a token payload, bound to the path and to the other query string parameters of the
+// callback URL.
+func (signer *Signer) sign(path string, query url.Values, encodedPayload string) string {
+	mac := hmac.New(sha256.New, signer.key)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(query.Encode()))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(encodedPayload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Middleware returns an http.Handler which verifies the signed token of the incoming callbacks before passing them to
+// the specified handler: unsigned callbacks are rejected with an HTTP 401 status code, while tampered and expired ones
+// are rejected with an HTTP 403 status code. A token is considered used once the next handler replies with a 2xx
+// status code, so that Verifalia can deliver again the callbacks which failed: further deliveries of a used token are
+// acknowledged without reaching the next handler, while the ones which arrive while the token is being used are
+// answered with an HTTP 409 status code, so that Verifalia delivers them again later. The correlation id of the token
+// is available to the next handler through CorrelationIdFromContext.
+func (signer *Signer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parsedToken, err := signer.verify(r.URL)
+
+		if err != nil {
+			status := http.StatusForbidden
+
+			if errors.Is(err, ErrMissingToken) {
+				status = http.StatusUnauthorized
+			}
+
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		switch signer.acquire(parsedToken) {
+		case tokenConsumed:
+			w.WriteHeader(http.StatusOK)
+			return
+		case tokenInFlight:
+			http.Error(w, "the callback is being processed", http.StatusConflict)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		succeeded := false
+
+		defer func() {
+			signer.release(parsedToken, succeeded)
+		}()
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), correlationIdKey{}, parsedToken.CorrelationId)))
+		succeeded = recorder.status >= 200 && recorder.status < 300
+	})
+}
+
+// CorrelationIdFromContext returns the correlation id of the callback being handled, as verified by the middleware
+// returned by Signer.Middleware.
+func CorrelationIdFromContext(ctx context.Context) (string, bool) {
+	correlationId, ok := ctx.Value(correlationIdKey{}).(string)
+	return correlationId, ok
+}
+
+type tokenState int
+
+const (
+	tokenAcquired tokenState = iota
+	tokenInFlight
+	tokenConsumed
+)
+
+func (signer *Signer) acquire(parsedToken *token) tokenState {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+
+	// Forget the expired tokens, which are rejected anyway
+
+	now := time.Now()
+
+	for nonce, expiresOn := range signer.consumed {
+		if now.After(expiresOn) {
+			delete(signer.consumed, nonce)
+		}
+	}
+
+	if _, ok := signer.consumed[parsedToken.Nonce]; ok {
+		return tokenConsumed
+	}
+
+	if signer.inFlight[parsedToken.Nonce] {
+		return tokenInFlight
+	}
+
+	signer.inFlight[parsedToken.Nonce] = true
+	return tokenAcquired
+}
+
+func (signer *Signer) release(parsedToken *token, succeeded bool) {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+
+	delete(signer.inFlight, parsedToken.Nonce)
+
+	if succeeded {
+		signer.consumed[parsedToken.Nonce] = time.UnixMilli(parsedToken.ExpiresOn)
+	}
+}
+
+// statusRecorder keeps track of the status code written by an http.Handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
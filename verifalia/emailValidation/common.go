@@ -38,6 +38,7 @@ import (
 	"time"
 )
 
+// Client allows to submit and manage email validations; it is safe for concurrent use by multiple goroutines.
 type Client struct {
 	RestClient rest.Client
 }
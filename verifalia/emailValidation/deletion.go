@@ -32,7 +32,7 @@ package emailValidation
  */
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
 	"net/http"
@@ -40,15 +40,28 @@ import (
 
 // Delete removes an emailValidation validation job from the Verifalia servers.
 func (client *Client) Delete(id string) error {
+	return client.delete(nil, id)
+}
+
+// DeleteWithContext removes an emailValidation validation job from the Verifalia servers, observing the specified
+// context for cancellation and deadlines.
+func (client *Client) DeleteWithContext(ctx context.Context, id string) error {
+	return client.delete(ctx, id)
+}
+
+func (client *Client) delete(ctx context.Context, id string) error {
 	response, err := client.RestClient.Invoke(rest.InvocationOptions{
 		Method:   http.MethodDelete,
 		Resource: fmt.Sprintf("email-validations/%v", id),
+		Context:  ctx,
 	})
 
 	if err != nil {
 		return err
 	}
 
+	defer response.Body.Close()
+
 	switch response.StatusCode {
 	case http.StatusOK, http.StatusGone:
 		{
@@ -57,7 +70,5 @@ func (client *Client) Delete(id string) error {
 		}
 	}
 
-	// TODO: Return the response body along with the error message
-
-	return errors.New(fmt.Sprintf("Unexpected HTTP response status code: %v", response.StatusCode))
+	return rest.NewAPIError(response)
 }
@@ -0,0 +1,273 @@
+package emailValidation
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"fmt"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/common"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+	"iter"
+	"net/http"
+	"strings"
+)
+
+// EntryFilter allows to restrict the entries of an email validation job returned by GetEntries.
+type EntryFilter struct {
+	// If not empty, only the entries with one of these validation statuses are returned; the Status enum-like object
+	// contains the supported values, for example: Status.MailboxDoesNotExist. Filtered on the server side.
+	Statuses []string
+
+	// If not empty, the entries with one of these validation statuses are excluded from the results. Filtered on the
+	// server side.
+	ExcludedStatuses []string
+
+	// If not empty, only the entries with one of these classifications are returned; the Classification enum-like
+	// object contains the supported values, for example: Classification.Undeliverable. As the Verifalia API does not
+	// filter by classification, these are filtered on the client side.
+	Classifications []string
+
+	// The maximum number of entries to return with each request to the Verifalia API, which may choose to override the
+	// specified limit if it is either too small or too big. This value does *not* limit the overall total number of
+	// returned entries.
+	Limit int
+
+	// An optional cursor, obtained through EntryIterator.Cursor, which allows to resume a previous iteration.
+	Cursor string
+}
+
+// EntryIterator iterates over the entries of an email validation job, automatically requesting the subsequent segments
+// from the Verifalia API as needed, for example:
+//  entries := client.EmailValidation.GetEntries(id, emailValidation.EntryFilter{})
+//
+//  for entries.Next() {
+//      fmt.Println(entries.Entry().EmailAddress)
+//  }
+//
+//  if err := entries.Err(); err != nil {
+//      panic(err)
+//  }
+// An EntryIterator is not safe for concurrent use by multiple goroutines.
+type EntryIterator struct {
+	paginator *common.Paginator[Entry]
+	ctx       context.Context
+	filter    EntryFilter
+
+	segment  []Entry
+	position int
+	current  Entry
+	err      error
+}
+
+// GetEntries returns an iterator over the entries of an email validation job previously submitted for processing,
+// restricted according to the specified filter.
+func (client *Client) GetEntries(id string, filter EntryFilter) *EntryIterator {
+	return client.GetEntriesWithContext(nil, id, filter)
+}
+
+// GetEntriesWithContext returns an iterator over the entries of an email validation job previously submitted for
+// processing, restricted according to the specified filter; the specified context is observed while requesting each
+// segment of entries from the Verifalia API.
+func (client *Client) GetEntriesWithContext(ctx context.Context, id string, filter EntryFilter) *EntryIterator {
+	return &EntryIterator{
+		paginator: client.newEntryPaginator(id, filter),
+		ctx:       ctx,
+		filter:    filter,
+	}
+}
+
+// AllEntries returns an iterator over the entries of an email validation job previously submitted for processing,
+// restricted according to the specified filter, for use with a range loop, for example:
+//  for entry, err := range client.EmailValidation.AllEntries(ctx, id, emailValidation.EntryFilter{}) {
+//      if err != nil {
+//          panic(err)
+//      }
+//
+//      fmt.Println(entry.EmailAddress)
+//  }
+// The segments of entries are requested only while iterating, thus breaking out of the loop does not leak any resource.
+func (client *Client) AllEntries(ctx context.Context, id string, filter EntryFilter) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		for entry, err := range client.newEntryPaginator(id, filter).All(ctx) {
+			if err != nil || filter.matchesClassification(entry) {
+				if !yield(entry, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// newEntryPaginator returns a paginator over the entries of an email validation job, according to the specified
+// filter.
+func (client *Client) newEntryPaginator(id string, filter EntryFilter) *common.Paginator[Entry] {
+	fetch := func(ctx context.Context, request common.SegmentRequest) (*common.ListingSegment[Entry], error) {
+		queryParams := filter.buildQueryParams()
+
+		if request.Limit > 0 {
+			queryParams["limit"] = []string{fmt.Sprintf("%v", request.Limit)}
+		}
+
+		if request.Cursor != nil {
+			request.Cursor.AddQueryParams(queryParams)
+		}
+
+		return listSegment[Entry](client.RestClient, rest.InvocationOptions{
+			Method:      http.MethodGet,
+			Resource:    fmt.Sprintf("email-validations/%v/entries", id),
+			QueryParams: queryParams,
+			Context:     ctx,
+		})
+	}
+
+	options := common.PaginatorOptions{
+		Limit: filter.Limit,
+	}
+
+	if filter.Cursor != "" {
+		options.Cursor = &common.ListingCursor{Cursor: filter.Cursor}
+	}
+
+	return common.NewPaginatorWithOptions(fetch, options)
+}
+
+// Next advances the iterator to the next entry, which is then available through Entry. It returns false when there
+// are no more entries or when an error occurs, in which case Err returns it.
+func (iterator *EntryIterator) Next() bool {
+	for {
+		for iterator.position < len(iterator.segment) {
+			entry := iterator.segment[iterator.position]
+			iterator.position++
+
+			if iterator.filter.matchesClassification(entry) {
+				iterator.current = entry
+				return true
+			}
+		}
+
+		if iterator.err != nil || !iterator.paginator.HasMore() {
+			return false
+		}
+
+		segment, err := iterator.paginator.NextSegment(iterator.ctx)
+
+		if err != nil {
+			iterator.err = err
+			return false
+		}
+
+		iterator.segment = segment
+		iterator.position = 0
+	}
+}
+
+// Entry returns the entry the iterator is currently positioned at.
+func (iterator *EntryIterator) Entry() Entry {
+	return iterator.current
+}
+
+// Err returns the eventual error occurred while iterating.
+func (iterator *EntryIterator) Err() error {
+	return iterator.err
+}
+
+// Cursor returns the cursor of the segment following the one being iterated, which can be passed through
+// EntryFilter.Cursor to resume the iteration later; it is empty if there are no more segments.
+func (iterator *EntryIterator) Cursor() string {
+	if cursor := iterator.paginator.Cursor(); cursor != nil {
+		return cursor.Cursor
+	}
+
+	return ""
+}
+
+// SegmentCursor returns the cursor of the segment being iterated, which can be passed through EntryFilter.Cursor to
+// resume the iteration later from that same segment, thus including its entries; it is empty if the segment is the
+// first one.
+func (iterator *EntryIterator) SegmentCursor() string {
+	if cursor := iterator.paginator.SegmentCursor(); cursor != nil {
+		return cursor.Cursor
+	}
+
+	return ""
+}
+
+// PreviousSegment steps the iterator back to the beginning of the segment which precedes the one being iterated,
+// requesting it again: the subsequent calls to Next return its entries, followed by the ones of the subsequent
+// segments. It returns false if the iterator did not iterate any segment before the current one or if an error
+// occurs, in which case Err returns it.
+func (iterator *EntryIterator) PreviousSegment() bool {
+	if iterator.err != nil || !iterator.paginator.HasPrevious() {
+		return false
+	}
+
+	segment, err := iterator.paginator.PreviousSegment(iterator.ctx)
+
+	if err != nil {
+		iterator.err = err
+		return false
+	}
+
+	iterator.segment = segment
+	iterator.position = 0
+
+	return true
+}
+
+// buildQueryParams returns the query parameters for the filters applied on the server side.
+func (filter EntryFilter) buildQueryParams() map[string][]string {
+	queryParams := make(map[string][]string)
+
+	if len(filter.Statuses) > 0 {
+		queryParams["status"] = []string{strings.Join(filter.Statuses, ",")}
+	}
+
+	if len(filter.ExcludedStatuses) > 0 {
+		queryParams["status:exclude"] = []string{strings.Join(filter.ExcludedStatuses, ",")}
+	}
+
+	return queryParams
+}
+
+func (filter EntryFilter) matchesClassification(entry Entry) bool {
+	if len(filter.Classifications) == 0 {
+		return true
+	}
+
+	for _, classification := range filter.Classifications {
+		if entry.Classification == classification {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package emailValidation
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"fmt"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ExportOptions allows to define the options for exporting the entries of an email validation job.
+type ExportOptions struct {
+	// A context.Context that can cancel the export. Useful if you wish to implement a timeout logic that abort the
+	// request if it takes too long.
+	Context context.Context
+
+	// If not empty, only the entries with one of these validation statuses are exported; the Status enum-like object
+	// contains the supported values, for example: Status.MailboxDoesNotExist.
+	Statuses []string
+
+	// If not empty, the entries with one of these validation statuses are excluded from the export.
+	ExcludedStatuses []string
+
+	// If positive, the time allowed for the export, including the download of the exported file, in place of the
+	// timeout of the HTTP client (30 seconds by default); a negative value disables the timeout altogether. Large jobs
+	// exported as Excel files may take longer than the timeout of the HTTP client.
+	Timeout time.Duration
+}
+
+// ExportEntries asks the Verifalia API to render the entries of a completed email validation job in the specified
+// format, which can be either rest.ContentType.TextCsv, rest.ContentType.ExcelXls or rest.ContentType.ExcelXlsx, and
+// streams the resulting file to the specified writer, without buffering it in memory. The exported file is the same
+// one produced by the Verifalia dashboard.
+func (client *Client) ExportEntries(id string, format string, writer io.Writer) error {
+	return client.ExportEntriesWithOptions(id, format, writer, nil)
+}
+
+// ExportEntriesWithOptions asks the Verifalia API to render the entries of a completed email validation job in the
+// specified format, which can be either rest.ContentType.TextCsv, rest.ContentType.ExcelXls or
+// rest.ContentType.ExcelXlsx, and streams the resulting file to the specified writer, without buffering it in memory.
+// Since the timeout of the HTTP client also covers the download of the file, the exports of large jobs may need a
+// longer ExportOptions.Timeout.
+func (client *Client) ExportEntriesWithOptions(id string, format string, writer io.Writer, options *ExportOptions) error {
+	switch format {
+	case rest.ContentType.TextCsv, rest.ContentType.ExcelXls, rest.ContentType.ExcelXlsx:
+	default:
+		return fmt.Errorf("unsupported export format: %v", format)
+	}
+
+	if options == nil {
+		options = &ExportOptions{}
+	}
+
+	filter := EntryFilter{
+		Statuses:         options.Statuses,
+		ExcludedStatuses: options.ExcludedStatuses,
+	}
+
+	response, err := client.RestClient.Invoke(rest.InvocationOptions{
+		Method:      http.MethodGet,
+		Resource:    fmt.Sprintf("email-validations/%v/entries", id),
+		QueryParams: filter.buildQueryParams(),
+		Context:     options.Context,
+		Timeout:     options.Timeout,
+		Headers: map[string]string{
+			"Accept": format,
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return rest.NewAPIError(response)
+	}
+
+	_, err = io.Copy(writer, response.Body)
+	return err
+}
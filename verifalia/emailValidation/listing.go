@@ -34,23 +34,37 @@ package emailValidation
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/common"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
 	"io/ioutil"
+	"iter"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// The format of the dates used by the listing filters.
+const listingDateFormat = "2006-01-02"
+
 type ListingResult struct {
 	JobOverview Overview
 	Error       error
 }
 
+// ListingField defines the job overview field the listing results are sorted by.
 type ListingField int
 
 const (
+	// Sorts the jobs by their creation date.
 	CreatedOn ListingField = iota
+
+	// Sorts the jobs by their submission date.
+	SubmittedOn
+
+	// Sorts the jobs by their completion date.
+	CompletedOn
 )
 
 type ListingOptions struct {
@@ -68,96 +82,356 @@ type ListingOptions struct {
 
 	// The direction of the listing.
 	Direction common.Direction
+
+	// An optional cursor, obtained through OverviewIterator.Cursor, which allows to resume a previous listing; the
+	// filters and the sorting of the resumed listing must be the same of the original one.
+	Cursor *common.ListingCursor
+
+	// If not zero, only the jobs created on or after this date are returned; the time of the day is ignored.
+	CreatedOnSince time.Time
+
+	// If not zero, only the jobs created on or before this date are returned; the time of the day is ignored.
+	CreatedOnUntil time.Time
+
+	// If not empty, only the jobs with one of these processing statuses are returned. The JobStatus enum-like object
+	// contains the supported values, for example: JobStatus.Completed
+	Statuses []string
+
+	// If not empty, the jobs with one of these processing statuses are excluded from the results.
+	ExcludedStatuses []string
+
+	// If not empty, only the jobs submitted by the Verifalia user with this unique ID are returned.
+	Owner string
+
+	// If not empty, only the jobs whose name contains this string are returned.
+	Name string
+}
+
+// OverviewIterator iterates over the email validation jobs returned by a listing, automatically requesting the
+// subsequent segments from the Verifalia API as needed, for example:
+//  jobs := client.EmailValidation.ListOverviews(emailValidation.ListingOptions{})
+//  defer jobs.Close()
+//
+//  for jobs.Next() {
+//      fmt.Println(jobs.Overview().Id)
+//  }
+//
+//  if err := jobs.Err(); err != nil {
+//      panic(err)
+//  }
+// As the segments are requested only while iterating, stopping the iteration early does not leak any resource; Close
+// also aborts an eventual in-flight request. Apart from Close, an OverviewIterator is not safe for concurrent use by
+// multiple goroutines.
+type OverviewIterator struct {
+	paginator *common.Paginator[Overview]
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	segment  []Overview
+	position int
+	current  Overview
+	closed   int32
+	err      error
+}
+
+// ListOverviews returns an iterator over the validation jobs, according to the specified options and user permissions.
+func (client *Client) ListOverviews(options ListingOptions) *OverviewIterator {
+	ctx := options.Context
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &OverviewIterator{
+		paginator: client.newOverviewPaginator(options),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// All returns an iterator over the validation jobs, according to the specified options and user permissions, for use
+// with a range loop, for example:
+//  for overview, err := range client.EmailValidation.All(ctx, emailValidation.ListingOptions{}) {
+//      if err != nil {
+//          panic(err)
+//      }
+//
+//      fmt.Println(overview.Id)
+//  }
+// The segments of the listing are requested only while iterating, thus breaking out of the loop does not leak any
+// resource. The specified context takes precedence over the Context field of the options.
+func (client *Client) All(ctx context.Context, options ListingOptions) iter.Seq2[Overview, error] {
+	return func(yield func(Overview, error) bool) {
+		client.newOverviewPaginator(options).All(ctx)(yield)
+	}
+}
+
+// Next advances the iterator to the next job, whose overview is then available through Overview. It returns false
+// when there are no more jobs, when the iterator is closed or when an error occurs, in which case Err returns it.
+func (iterator *OverviewIterator) Next() bool {
+	for {
+		if atomic.LoadInt32(&iterator.closed) != 0 {
+			return false
+		}
+
+		if iterator.position < len(iterator.segment) {
+			iterator.current = iterator.segment[iterator.position]
+			iterator.position++
+
+			return true
+		}
+
+		if iterator.err != nil || !iterator.paginator.HasMore() {
+			return false
+		}
+
+		segment, err := iterator.paginator.NextSegment(iterator.ctx)
+
+		if err != nil {
+			if atomic.LoadInt32(&iterator.closed) == 0 {
+				iterator.err = err
+			}
+
+			return false
+		}
+
+		iterator.segment = segment
+		iterator.position = 0
+	}
+}
+
+// Overview returns the overview of the job the iterator is currently positioned at.
+func (iterator *OverviewIterator) Overview() Overview {
+	return iterator.current
+}
+
+// Err returns the eventual error occurred while iterating.
+func (iterator *OverviewIterator) Err() error {
+	return iterator.err
+}
+
+// Cursor returns the cursor of the segment following the one being iterated, which can be passed through
+// ListingOptions.Cursor to resume the listing later, for example to checkpoint a long-running synchronization; the
+// resumed listing does not include the jobs of the current segment. It is nil if there are no more segments.
+func (iterator *OverviewIterator) Cursor() *common.ListingCursor {
+	return iterator.paginator.Cursor()
+}
+
+// SegmentCursor returns the cursor of the segment being iterated, which can be passed through ListingOptions.Cursor to
+// resume the listing later from that same segment, thus including its jobs; it is nil if the segment is the first one
+// of the listing.
+func (iterator *OverviewIterator) SegmentCursor() *common.ListingCursor {
+	return iterator.paginator.SegmentCursor()
+}
+
+// PreviousSegment steps the iterator back to the beginning of the segment which precedes the one being iterated,
+// requesting it again: the subsequent calls to Next return its jobs, followed by the ones of the subsequent segments.
+// It returns false if the iterator did not iterate any segment before the current one, if the iterator is closed or
+// if an error occurs, in which case Err returns it.
+func (iterator *OverviewIterator) PreviousSegment() bool {
+	if atomic.LoadInt32(&iterator.closed) != 0 || iterator.err != nil || !iterator.paginator.HasPrevious() {
+		return false
+	}
+
+	segment, err := iterator.paginator.PreviousSegment(iterator.ctx)
+
+	if err != nil {
+		if atomic.LoadInt32(&iterator.closed) == 0 {
+			iterator.err = err
+		}
+
+		return false
+	}
+
+	iterator.segment = segment
+	iterator.position = 0
+
+	return true
+}
+
+// Close stops the iteration, aborting an eventual in-flight request; it can be called multiple times, even from a
+// different goroutine than the one iterating.
+func (iterator *OverviewIterator) Close() error {
+	atomic.StoreInt32(&iterator.closed, 1)
+	iterator.cancel()
+
+	return nil
+}
+
+// newOverviewPaginator returns a paginator over the validation jobs, according to the specified options.
+func (client *Client) newOverviewPaginator(options ListingOptions) *common.Paginator[Overview] {
+	fetch := func(ctx context.Context, request common.SegmentRequest) (*common.ListingSegment[Overview], error) {
+		queryParams := buildListingQueryParams(options)
+
+		if request.Limit > 0 {
+			queryParams["limit"] = []string{fmt.Sprintf("%v", request.Limit)}
+		}
+
+		if request.Cursor != nil {
+			request.Cursor.AddQueryParams(queryParams)
+		} else {
+			switch options.OrderBy {
+			case CreatedOn:
+				queryParams["sort"] = []string{buildListingSort("createdOn", options.Direction)}
+			case SubmittedOn:
+				queryParams["sort"] = []string{buildListingSort("submittedOn", options.Direction)}
+			case CompletedOn:
+				queryParams["sort"] = []string{buildListingSort("completedOn", options.Direction)}
+			}
+		}
+
+		segment, err := listSegment[overview](client.RestClient, rest.InvocationOptions{
+			Method:      http.MethodGet,
+			Resource:    "email-validations",
+			QueryParams: queryParams,
+			Context:     ctx,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return mapSegment(segment, buildOverview), nil
+	}
+
+	return common.NewPaginatorWithOptions(fetch, common.PaginatorOptions{
+		Cursor: options.Cursor,
+		Limit:  options.Limit,
+	})
 }
 
 // List returns a list of validation jobs according to the user permissions.
+// To stop the listing before reaching its end without leaking resources, use ListWithOptions with a Context and
+// cancel it, or use ListOverviews instead.
+//
+// Deprecated: use All or ListOverviews, which can be stopped at any time without leaking resources.
 func (client *Client) List() chan ListingResult {
 	return client.ListWithOptions(ListingOptions{})
 }
 
 // ListWithOptions returns a list of validation jobs according to the specified options and user permissions.
+// To stop the listing before reaching its end without leaking resources, cancel the Context of the options; as an
+// alternative, use ListOverviews, which can be stopped at any time.
+//
+// Deprecated: use All or ListOverviews, which can be stopped at any time without leaking resources.
 func (client *Client) ListWithOptions(options ListingOptions) chan ListingResult {
 	// The channel is unbuffered, so that the caller can choose when / whether to abort the listing
 
 	results := make(chan ListingResult)
 
-	go func() {
-		defer close(results)
+	var done <-chan struct{}
 
-		// First page
+	if options.Context != nil {
+		done = options.Context.Done()
+	}
 
-		filterParams := make(map[string][]string)
+	// Once the context is done, the cancellation is reported only to a caller which is still waiting for the results,
+	// so that a caller which stopped reading does not block the goroutine forever
 
-		if options.Limit > 0 {
-			filterParams["limit"] = []string{fmt.Sprintf("%v", options.Limit)}
+	reportCancellation := func() {
+		select {
+		case results <- ListingResult{Error: options.Context.Err()}:
+		default:
 		}
+	}
 
-		switch options.OrderBy {
-		case CreatedOn:
-			{
-				if options.Direction == common.Backward {
-					filterParams["sort"] = []string{"-createdOn"}
-				} else {
-					filterParams["sort"] = []string{"createdOn"}
-				}
+	go func() {
+		defer close(results)
+
+		iterator := client.ListOverviews(options)
+		defer iterator.Close()
+
+		for iterator.Next() {
+			select {
+			case results <- ListingResult{JobOverview: iterator.Overview()}:
+			case <-done:
+				reportCancellation()
+				return
 			}
 		}
 
-		invOptions := rest.InvocationOptions{
-			Method:      http.MethodGet,
-			Resource:    "email-validations",
-			QueryParams: filterParams,
-			Context:     options.Context,
+		if err := iterator.Err(); err != nil {
+			select {
+			case results <- ListingResult{Error: err}:
+			case <-done:
+				reportCancellation()
+			}
 		}
+	}()
 
-		// Iterate over the subsequent segments
+	return results
+}
 
-		for {
-			segment, err := client.listSegment(invOptions)
+// buildListingQueryParams returns the query parameters for the filters of a listing request, which are sent along
+// with each segment request.
+func buildListingQueryParams(options ListingOptions) map[string][]string {
+	queryParams := make(map[string][]string)
 
-			if err != nil {
-				results <- ListingResult{
-					Error: err,
-				}
+	if !options.CreatedOnSince.IsZero() {
+		queryParams["createdOn:since"] = []string{options.CreatedOnSince.Format(listingDateFormat)}
+	}
 
-				break
-			}
+	if !options.CreatedOnUntil.IsZero() {
+		queryParams["createdOn:until"] = []string{options.CreatedOnUntil.Format(listingDateFormat)}
+	}
 
-			for _, jobOverview := range *segment.Data {
-				results <- ListingResult{
-					JobOverview: jobOverview,
-				}
-			}
+	if len(options.Statuses) > 0 {
+		queryParams["status"] = []string{strings.Join(options.Statuses, ",")}
+	}
 
-			if !segment.Meta.IsTruncated {
-				break
-			}
+	if len(options.ExcludedStatuses) > 0 {
+		queryParams["status:exclude"] = []string{strings.Join(options.ExcludedStatuses, ",")}
+	}
 
-			// Prepare for next page request
-
-			invOptions = rest.InvocationOptions{
-				Method:   http.MethodGet,
-				Resource: "email-validations",
-				QueryParams: map[string][]string{
-					"cursor": {
-						segment.Meta.Cursor,
-					},
-				},
-				Context: options.Context,
-			}
+	if options.Owner != "" {
+		queryParams["owner"] = []string{options.Owner}
+	}
+
+	if options.Name != "" {
+		queryParams["name"] = []string{options.Name}
+	}
+
+	return queryParams
+}
+
+func buildListingSort(field string, direction common.Direction) string {
+	if direction == common.Backward {
+		return "-" + field
+	}
+
+	return field
+}
+
+// mapSegment converts the items of a listing segment through the specified function.
+func mapSegment[T any, U any](segment *common.ListingSegment[T], mapper func(item T) U) *common.ListingSegment[U] {
+	result := &common.ListingSegment[U]{
+		Meta: segment.Meta,
+	}
+
+	if segment.Data != nil {
+		data := make([]U, len(*segment.Data))
+
+		for i, item := range *segment.Data {
+			data[i] = mapper(item)
 		}
-	}()
 
-	return results
+		result.Data = &data
+	}
+
+	return result
 }
 
-func (client *Client) listSegment(invocationOptions rest.InvocationOptions) (*common.ListingSegment[Overview], error) {
-	response, err := client.RestClient.Invoke(invocationOptions)
+func listSegment[T any](restClient rest.Client, invocationOptions rest.InvocationOptions) (*common.ListingSegment[T], error) {
+	response, err := restClient.Invoke(invocationOptions)
 
 	if err != nil {
 		return nil, err
 	}
 
+	defer response.Body.Close()
+
 	if response.StatusCode == http.StatusOK {
 		responseData, err := ioutil.ReadAll(response.Body)
 
@@ -165,7 +439,7 @@ func (client *Client) listSegment(invocationOptions rest.InvocationOptions) (*co
 			return nil, err
 		}
 
-		segment := common.ListingSegment[Overview]{}
+		segment := common.ListingSegment[T]{}
 
 		if err := json.Unmarshal(responseData, &segment); err != nil {
 			return nil, err
@@ -173,8 +447,6 @@ func (client *Client) listSegment(invocationOptions rest.InvocationOptions) (*co
 
 		return &segment, nil
 	} else {
-		// TODO: Return the response body along with the error message
-
-		return nil, errors.New(fmt.Sprintf("Unexpected HTTP response status code: %v", response.StatusCode))
+		return nil, rest.NewAPIError(response)
 	}
 }
@@ -0,0 +1,198 @@
+package emailValidation
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// EtaPolling returns a polling strategy, meant to be used through WaitingOptions.WaitForNextPoll, which waits for the
+// estimated time remaining reported by the Verifalia API, bounded by the specified minimum and maximum intervals;
+// should the API not report any estimate, the minimum interval is used. Zero intervals are replaced with defaults
+// which scale with the number of entries of the job.
+// This is the strategy used by default while waiting for the completion of a job.
+func EtaPolling(minInterval time.Duration, maxInterval time.Duration) func(overview Overview, ctx context.Context) error {
+	return func(overview Overview, ctx context.Context) error {
+		lowerBound, upperBound := pollingBounds(overview, minInterval, maxInterval)
+		interval := lowerBound
+
+		if overview.Progress != nil && overview.Progress.EstimatedTimeRemaining != nil {
+			interval = clampInterval(*overview.Progress.EstimatedTimeRemaining, lowerBound, upperBound)
+		}
+
+		return sleepUntilNextPoll(ctx, interval)
+	}
+}
+
+// ExponentialBackoffPolling returns a polling strategy, meant to be used through WaitingOptions.WaitForNextPoll, which
+// waits an increasing interval between the polls, starting from initialInterval and growing by the specified factor
+// up to maxInterval. Zero intervals are replaced with defaults which scale with the number of entries of the job,
+// while factors not greater than 1 are replaced with 2.
+// The backoff is kept for each waiting process, thus the returned function can be shared among multiple waiting
+// processes, each starting from initialInterval; when invoked outside of a waiting process, the interval grows with
+// each invocation of the returned function.
+func ExponentialBackoffPolling(initialInterval time.Duration, maxInterval time.Duration, factor float64) func(overview Overview, ctx context.Context) error {
+	if factor <= 1 {
+		factor = 2
+	}
+
+	var detachedState pollingState
+
+	return func(overview Overview, ctx context.Context) error {
+		lowerBound, upperBound := pollingBounds(overview, initialInterval, maxInterval)
+
+		state, ok := ctx.Value(pollingStateKey{}).(*pollingState)
+
+		if !ok {
+			state = &detachedState
+		}
+
+		interval := float64(lowerBound) * math.Pow(factor, float64(state.nextWait()))
+
+		if interval > float64(upperBound) {
+			interval = float64(upperBound)
+		}
+
+		return sleepUntilNextPoll(ctx, clampInterval(time.Duration(interval), lowerBound, upperBound))
+	}
+}
+
+// pollingState is the state of a waiting process, available to the polling strategies through the context passed to
+// WaitingOptions.WaitForNextPoll.
+type pollingState struct {
+	noOfWaits int64
+}
+
+type pollingStateKey struct{}
+
+// nextWait returns the number of the previous waits for the next poll and counts the current one.
+func (state *pollingState) nextWait() int64 {
+	return atomic.AddInt64(&state.noOfWaits, 1) - 1
+}
+
+// The time the Verifalia API holds each poll by default, while long polling: well below the default timeout of the
+// HTTP clients (30 seconds), which is anyway extended by the wait time of each poll.
+const defaultLongPollWaitTime = 20 * time.Second
+
+// LongPolling returns the waiting options which leave the waiting to the Verifalia API: each poll is held by the API
+// until either the job completes or the specified pollWaitTime elapses, while the polls are spaced by just the
+// specified minimum interval. Zero values are replaced with 20 seconds and one second, respectively. The other fields
+// of the returned options can be set as usual, for example:
+//  options := emailValidation.LongPolling(0, 0)
+//  options.Context = ctx
+//  job, err = client.EmailValidation.WaitForCompletionWithOptions(job, &options)
+func LongPolling(pollWaitTime time.Duration, minInterval time.Duration) WaitingOptions {
+	if pollWaitTime <= 0 {
+		pollWaitTime = defaultLongPollWaitTime
+	}
+
+	if minInterval <= 0 {
+		minInterval = time.Second
+	}
+
+	return WaitingOptions{
+		PollWaitTime: pollWaitTime,
+		WaitForNextPoll: func(overview Overview, ctx context.Context) error {
+			return sleepUntilNextPoll(ctx, minInterval)
+		},
+	}
+}
+
+func defaultWaitForNextPoll(overview Overview, ctx context.Context) error {
+	return EtaPolling(0, 0)(overview, ctx)
+}
+
+// pollingBounds returns the specified polling interval bounds, replacing the zero ones with defaults which scale with
+// the number of entries of the job: small jobs are polled often, while large ones are polled at a slower pace.
+func pollingBounds(overview Overview, minInterval time.Duration, maxInterval time.Duration) (time.Duration, time.Duration) {
+	if minInterval <= 0 {
+		switch {
+		case overview.NoOfEntries <= 10:
+			minInterval = 500 * time.Millisecond
+		case overview.NoOfEntries <= 100:
+			minInterval = time.Second
+		case overview.NoOfEntries <= 1000:
+			minInterval = 2500 * time.Millisecond
+		case overview.NoOfEntries <= 10000:
+			minInterval = 5 * time.Second
+		case overview.NoOfEntries <= 100000:
+			minInterval = 15 * time.Second
+		default:
+			minInterval = 30 * time.Second
+		}
+	}
+
+	if maxInterval <= 0 {
+		maxInterval = 10 * minInterval
+
+		if maxInterval > 5*time.Minute {
+			maxInterval = 5 * time.Minute
+		}
+	}
+
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+
+	return minInterval, maxInterval
+}
+
+func clampInterval(interval time.Duration, minInterval time.Duration, maxInterval time.Duration) time.Duration {
+	if interval < minInterval {
+		return minInterval
+	}
+
+	if interval > maxInterval {
+		return maxInterval
+	}
+
+	return interval
+}
+
+func sleepUntilNextPoll(ctx context.Context, interval time.Duration) error {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	// Either wait for the polling interval or until the context is cancelled
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	// If the context is cancelled, let the caller know
+
+	return ctx.Err()
+}
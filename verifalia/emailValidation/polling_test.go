@@ -0,0 +1,32 @@
+package emailValidation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollingBounds(t *testing.T) {
+	var previousMin, previousMax time.Duration
+
+	// The default bounds grow along with the number of entries of the job, up to a cap
+
+	for _, noOfEntries := range []uint{1, 50, 500, 5000, 50000, 500000} {
+		minInterval, maxInterval := pollingBounds(Overview{NoOfEntries: noOfEntries}, 0, 0)
+
+		if minInterval <= previousMin || maxInterval < previousMax || maxInterval < minInterval || maxInterval > 5*time.Minute {
+			t.Fatalf("unexpected bounds for %v entries: %v - %v", noOfEntries, minInterval, maxInterval)
+		}
+
+		previousMin, previousMax = minInterval, maxInterval
+	}
+
+	// The specified bounds are used as they are, as long as they are consistent
+
+	if minInterval, maxInterval := pollingBounds(Overview{NoOfEntries: 500000}, time.Second, 3*time.Second); minInterval != time.Second || maxInterval != 3*time.Second {
+		t.Fatalf("unexpected bounds: %v - %v", minInterval, maxInterval)
+	}
+
+	if minInterval, maxInterval := pollingBounds(Overview{NoOfEntries: 1}, time.Minute, time.Second); minInterval != time.Minute || maxInterval != time.Minute {
+		t.Fatalf("unexpected bounds: %v - %v", minInterval, maxInterval)
+	}
+}
@@ -34,7 +34,6 @@ package emailValidation
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/common"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
@@ -46,35 +45,63 @@ import (
 
 // RetrievalOptions allows to define retrieval options for an e-mail verification job.
 type RetrievalOptions struct {
+	// A context.Context that can cancel the retrieval. Useful if you wish to implement a timeout logic that abort the
+	// request if it takes too long.
+	Context context.Context
+
 	// Defines how much time to ask the Verifalia API to wait for the completion of the job on the server side, during the
 	// job retrieval request.
 	RetrievalWaitTime time.Duration
+
+	// An optional function which receives the entries of the job one segment (page) at a time, as soon as each segment
+	// is downloaded from the Verifalia API; if specified, the entries are not collected into the Entries field of the
+	// returned job, thus avoiding to keep the results of large jobs in memory. Returning an error stops the retrieval
+	// and makes it fail with that error.
+	EntrySegmentHandler func(entries []Entry) error
 }
 
 // Get fetches an email validation job previously submitted for processing.
+// If the job never existed, the returned error matches rest.ErrNotFound (through errors.Is); if the job has been
+// deleted or is expired, the returned error matches rest.ErrGone.
 func (client *Client) Get(id string) (*Job, error) {
-	return client.GetWithOptions(id, nil)
+	return client.get(nil, id, nil)
+}
+
+// GetWithContext fetches an email validation job previously submitted for processing, observing the specified
+// context for cancellation and deadlines.
+// If the job never existed, the returned error matches rest.ErrNotFound (through errors.Is); if the job has been
+// deleted or is expired, the returned error matches rest.ErrGone.
+func (client *Client) GetWithContext(ctx context.Context, id string) (*Job, error) {
+	return client.get(ctx, id, nil)
 }
 
 // GetWithOptions fetches an email validation job previously submitted for processing.
+// If the job never existed, the returned error matches rest.ErrNotFound (through errors.Is); if the job has been
+// deleted or is expired, the returned error matches rest.ErrGone.
 func (client *Client) GetWithOptions(id string, options *RetrievalOptions) (*Job, error) {
-	var queryParams map[string][]string
+	var ctx context.Context
 
 	if options != nil {
-		queryParams = make(map[string][]string)
-		queryParams["waitTime"] = []string{fmt.Sprintf("%v", options.RetrievalWaitTime.Seconds())}
+		ctx = options.Context
 	}
 
+	return client.get(ctx, id, options)
+}
+
+func (client *Client) get(ctx context.Context, id string, options *RetrievalOptions) (*Job, error) {
 	response, err := client.RestClient.Invoke(rest.InvocationOptions{
 		Method:      http.MethodGet,
 		Resource:    fmt.Sprintf("email-validations/%v", id),
-		QueryParams: queryParams,
+		QueryParams: buildRetrievalQueryParams(options),
+		Context:     ctx,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	defer response.Body.Close()
+
 	switch response.StatusCode {
 	case http.StatusOK, http.StatusAccepted:
 		{
@@ -91,48 +118,88 @@ func (client *Client) GetWithOptions(id string, options *RetrievalOptions) (*Job
 				return nil, err
 			}
 
-			// Empty context here because this specific API returns the job data as a whole
+			var entrySegmentHandler func(entries []Entry) error
 
-			result, err := client.buildJob(partial, context.TODO())
+			if options != nil {
+				entrySegmentHandler = options.EntrySegmentHandler
+			}
+
+			result, err := client.buildJob(partial, ctx, entrySegmentHandler)
 
 			return result, err
 		}
-	case http.StatusNotFound, http.StatusGone:
-		return nil, nil
 	}
 
-	// TODO: Return the response body along with the error message
-
-	return nil, errors.New(fmt.Sprintf("Unexpected HTTP response status code: %v", response.StatusCode))
+	return nil, rest.NewAPIError(response)
 }
 
-func (client *Client) buildJob(partial partialJob, ctx context.Context) (*Job, error) {
-	// TODO: Retrieve the other entries from the API (needed when we will support the filtering API)
-
+func (client *Client) buildJob(partial partialJob, ctx context.Context, entrySegmentHandler func(entries []Entry) error) (*Job, error) {
 	var result = &Job{
 		Overview: buildOverview(partial.Overview),
 	}
 
-	if partial.Entries != nil && partial.Entries.Data != nil {
-		result.Entries = *partial.Entries.Data
+	if partial.Entries == nil {
+		return result, nil
 	}
 
-	if partial.Overview.Progress != nil {
-		result.Overview.Progress = &Progress{
-			Percentage: partial.Overview.Progress.Percentage,
+	// The API returns the first segment of the entries along with the job: follow the cursor to retrieve the
+	// other segments, if any
+
+	segment := partial.Entries
+
+	for segmentNo := 1; ; segmentNo++ {
+		if segment.Data != nil {
+			if entrySegmentHandler != nil {
+				if err := entrySegmentHandler(*segment.Data); err != nil {
+					return nil, err
+				}
+			} else {
+				result.Entries = append(result.Entries, *segment.Data...)
+			}
 		}
 
-		if partial.Overview.Progress.EstimatedTimeRemaining != "" {
-			eta := common.TimeSpanStringToDuration(partial.Overview.Progress.EstimatedTimeRemaining)
-			result.Overview.Progress.EstimatedTimeRemaining = &eta
+		if segment.Meta == nil || !segment.Meta.IsTruncated {
+			break
+		}
+
+		var err error
+
+		segment, err = listSegment[Entry](client.RestClient, rest.InvocationOptions{
+			Method:   http.MethodGet,
+			Resource: fmt.Sprintf("email-validations/%v/entries", result.Overview.Id),
+			QueryParams: map[string][]string{
+				"cursor": {
+					segment.Meta.Cursor,
+				},
+			},
+			Context: ctx,
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve the entries segment #%d of the job %v: %w", segmentNo+1,
+				result.Overview.Id, err)
 		}
 	}
 
 	return result, nil
 }
 
+// UnmarshalOverview parses an overview of an email validation job, in the JSON format used by the Verifalia API (for
+// example, in the payload of the completion callbacks).
+func UnmarshalOverview(data []byte) (*Overview, error) {
+	var rawOverview overview
+
+	if err := json.Unmarshal(data, &rawOverview); err != nil {
+		return nil, err
+	}
+
+	result := buildOverview(rawOverview)
+
+	return &result, nil
+}
+
 func buildOverview(rawOverview overview) Overview {
-	return Overview{
+	result := Overview{
 		Id:            rawOverview.Id,
 		SubmittedOn:   rawOverview.SubmittedOn,
 		CompletedOn:   rawOverview.CompletedOn,
@@ -147,32 +214,63 @@ func buildOverview(rawOverview overview) Overview {
 		Status:        rawOverview.Status,
 		NoOfEntries:   rawOverview.NoOfEntries,
 	}
+
+	if rawOverview.Progress != nil {
+		result.Progress = &Progress{
+			Percentage: rawOverview.Progress.Percentage,
+		}
+
+		if rawOverview.Progress.EstimatedTimeRemaining != "" {
+			eta := common.TimeSpanStringToDuration(rawOverview.Progress.EstimatedTimeRemaining)
+			result.Progress.EstimatedTimeRemaining = &eta
+		}
+	}
+
+	return result
 }
 
 // GetOverview fetches an overview of an email validation job previously submitted for processing.
+// If the job never existed, the returned error matches rest.ErrNotFound (through errors.Is); if the job has been
+// deleted or is expired, the returned error matches rest.ErrGone.
 func (client *Client) GetOverview(id string) (*Overview, error) {
-	return client.GetOverviewWithOptions(id, nil)
+	return client.getOverview(nil, id, nil)
+}
+
+// GetOverviewWithContext fetches an overview of an email validation job previously submitted for processing,
+// observing the specified context for cancellation and deadlines.
+// If the job never existed, the returned error matches rest.ErrNotFound (through errors.Is); if the job has been
+// deleted or is expired, the returned error matches rest.ErrGone.
+func (client *Client) GetOverviewWithContext(ctx context.Context, id string) (*Overview, error) {
+	return client.getOverview(ctx, id, nil)
 }
 
 // GetOverviewWithOptions fetches an overview of an email validation job previously submitted for processing.
+// If the job never existed, the returned error matches rest.ErrNotFound (through errors.Is); if the job has been
+// deleted or is expired, the returned error matches rest.ErrGone.
 func (client *Client) GetOverviewWithOptions(id string, options *RetrievalOptions) (*Overview, error) {
-	var queryParams map[string][]string
+	var ctx context.Context
 
 	if options != nil {
-		queryParams = make(map[string][]string)
-		queryParams["waitTime"] = []string{fmt.Sprintf("%v", options.RetrievalWaitTime.Seconds())}
+		ctx = options.Context
 	}
 
+	return client.getOverview(ctx, id, options)
+}
+
+func (client *Client) getOverview(ctx context.Context, id string, options *RetrievalOptions) (*Overview, error) {
 	response, err := client.RestClient.Invoke(rest.InvocationOptions{
 		Method:      http.MethodGet,
 		Resource:    fmt.Sprintf("email-validations/%v/overview", id),
-		QueryParams: queryParams,
+		QueryParams: buildRetrievalQueryParams(options),
+		Context:     ctx,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	defer response.Body.Close()
+
 	switch response.StatusCode {
 	case http.StatusOK, http.StatusAccepted:
 		{
@@ -193,11 +291,18 @@ func (client *Client) GetOverviewWithOptions(id string, options *RetrievalOption
 
 			return &overview, err
 		}
-	case http.StatusNotFound, http.StatusGone:
-		return nil, nil
 	}
 
-	// TODO: Return the response body along with the error message
+	return nil, rest.NewAPIError(response)
+}
+
+func buildRetrievalQueryParams(options *RetrievalOptions) map[string][]string {
+	if options == nil {
+		return nil
+	}
+
+	queryParams := make(map[string][]string)
+	queryParams["waitTime"] = []string{fmt.Sprintf("%v", options.RetrievalWaitTime.Seconds())}
 
-	return nil, errors.New(fmt.Sprintf("Unexpected HTTP response status code: %v", response.StatusCode))
+	return queryParams
 }
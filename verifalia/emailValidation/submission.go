@@ -83,16 +83,70 @@ type SubmissionOptions struct {
 	// A verification job can be deleted anytime prior to its retention period through the Delete() function.
 	Retention time.Duration
 
-	// An optional URL which Verifalia will invoke once the results for this job are ready.
+	// An optional URL which Verifalia will invoke once the results for this job are ready. Ignored if Callback is set.
 	CompletionCallback url.URL
 
-	// TODO: Introduce a new CompletionCallback object which includes the additional options available in Verifalia API v2.4+ (breaking change)
+	// An optional completion callback which Verifalia will invoke once the results for this job are ready, along with
+	// its additional settings; takes precedence over CompletionCallback.
+	Callback *CompletionCallback
 
 	// Defines how much time to ask the Verifalia API to wait for the completion of the job on the server side, during the
 	// initial job submission request.
 	SubmissionWaitTime time.Duration
 }
 
+// CompletionCallback defines the URL Verifalia will invoke once the results of an email validation job are ready,
+// along with the settings of the callback.
+type CompletionCallback struct {
+	// The URL Verifalia will invoke once the results for the job are ready.
+	Url url.URL
+
+	// The optional version of the callback schema. The CallbackVersion enum-like object contains the supported values,
+	// for example: CallbackVersion.V1_1
+	Version string
+
+	// If true, Verifalia won't validate the TLS certificate of the callback URL, which allows to use self-signed
+	// certificates. Not recommended for production environments.
+	SkipServerCertificateValidation bool
+
+	// Any additional callback setting supported by the Verifalia API, which is sent along with the other ones as-is;
+	// the explicit fields above take precedence over the settings with the same name.
+	AdditionalSettings map[string]interface{}
+}
+
+// CallbackVersion provides enumerated-like values for the supported versions of the completion callback schema.
+var CallbackVersion = struct {
+	// The original version of the callback schema.
+	V1_0 string
+
+	// Version 1.1 of the callback schema, which includes additional details about the completed job (available
+	// since Verifalia API v2.4).
+	V1_1 string
+}{
+	V1_0: "1.0",
+	V1_1: "1.1",
+}
+
+func (callback CompletionCallback) buildSettings() map[string]interface{} {
+	settings := make(map[string]interface{}, len(callback.AdditionalSettings)+3)
+
+	for name, value := range callback.AdditionalSettings {
+		settings[name] = value
+	}
+
+	settings["url"] = callback.Url.String()
+
+	if callback.Version != "" {
+		settings["version"] = callback.Version
+	}
+
+	if callback.SkipServerCertificateValidation {
+		settings["skipServerCertificateValidation"] = true
+	}
+
+	return settings
+}
+
 // FileSubmissionOptions allows to define file-specific submission options for an e-mail verification job.
 type FileSubmissionOptions struct {
 	// The MIME Content-Type of the file data. The ContentType enum-like object contains the supported values, for
@@ -127,14 +181,12 @@ type FileSubmissionOptions struct {
 // Internal struct used to serialize the validation request
 
 type validationRequestBase struct {
-	Name          *string `json:"name,omitempty"`
-	Quality       *string `json:"quality,omitempty"`
-	Deduplication *string `json:"deduplication,omitempty"`
-	Priority      *uint8  `json:"priority,omitempty"`
-	Retention     *string `json:"retention,omitempty"`
-	Callback      *struct {
-		Url string `json:"url"`
-	} `json:"callback,omitempty"`
+	Name          *string                `json:"name,omitempty"`
+	Quality       *string                `json:"quality,omitempty"`
+	Deduplication *string                `json:"deduplication,omitempty"`
+	Priority      *uint8                 `json:"priority,omitempty"`
+	Retention     *string                `json:"retention,omitempty"`
+	Callback      map[string]interface{} `json:"callback,omitempty"`
 }
 
 type validationRequest struct {
@@ -298,7 +350,11 @@ func (client *Client) SubmitFileWithOptions(file os.File, fileOptions *FileSubmi
 func (client *Client) SubmitFileReaderWithOptions(reader io.Reader, fileOptions *FileSubmissionOptions, options *SubmissionOptions) (*Job, error) {
 	contentType := rest.ContentType.TextPlain
 
-	if fileOptions != nil && fileOptions.ContentType != "" {
+	if fileOptions == nil {
+		fileOptions = &FileSubmissionOptions{}
+	}
+
+	if fileOptions.ContentType != "" {
 		contentType = fileOptions.ContentType
 	}
 
@@ -364,12 +420,14 @@ func (client *Client) SubmitFileReaderWithOptions(reader io.Reader, fileOptions
 
 	// Invoke the API through the common submission code path
 
+	var queryParams map[string][]string
+
 	if options != nil {
 		ctx = options.Context
-	}
 
-	queryParams := make(map[string][]string)
-	queryParams["waitTime"] = []string{fmt.Sprintf("%v", options.SubmissionWaitTime.Seconds())}
+		queryParams = make(map[string][]string)
+		queryParams["waitTime"] = []string{fmt.Sprintf("%v", options.SubmissionWaitTime.Seconds())}
+	}
 
 	return client.submit(rest.InvocationOptions{
 		Method: http.MethodPost,
@@ -378,7 +436,7 @@ func (client *Client) SubmitFileReaderWithOptions(reader io.Reader, fileOptions
 		},
 		Resource:    "email-validations",
 		QueryParams: queryParams,
-		Body:        body,
+		Body:        bytes.NewReader(body.Bytes()),
 		Context:     ctx,
 	})
 }
@@ -401,12 +459,10 @@ func fillSubmissionRequestOptions(request *validationRequestBase, options *Submi
 			retentionAsTimeSpan := common.DurationToTimeSpanString(options.Retention)
 			request.Retention = &retentionAsTimeSpan
 		}
-		if options.CompletionCallback.Scheme != "" {
-			request.Callback = &struct {
-				Url string `json:"url"`
-			}{
-				options.CompletionCallback.String(),
-			}
+		if options.Callback != nil {
+			request.Callback = options.Callback.buildSettings()
+		} else if options.CompletionCallback.Scheme != "" {
+			request.Callback = CompletionCallback{Url: options.CompletionCallback}.buildSettings()
 		}
 	}
 }
@@ -437,6 +493,8 @@ func (client *Client) submit(invocationOptions rest.InvocationOptions) (*Job, er
 		return nil, err
 	}
 
+	defer response.Body.Close()
+
 	if response.StatusCode == http.StatusOK || response.StatusCode == http.StatusAccepted {
 		responseData, err := ioutil.ReadAll(response.Body)
 
@@ -452,10 +510,10 @@ func (client *Client) submit(invocationOptions rest.InvocationOptions) (*Job, er
 			return nil, err
 		}
 
-		result, err := client.buildJob(partial, invocationOptions.Context)
+		result, err := client.buildJob(partial, invocationOptions.Context, nil)
 
 		return result, err
 	}
 
-	return nil, fmt.Errorf("unexpected HTTP response: %d", response.StatusCode)
+	return nil, rest.NewAPIError(response)
 }
@@ -33,6 +33,7 @@ package emailValidation
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
@@ -42,25 +43,78 @@ type WaitingOptions struct {
 	// request if it takes too long.
 	Context context.Context
 
-	// A waiting function which pauses the current execution until the time of the next polling.
+	// A waiting function which pauses the current execution until the time of the next polling. The EtaPolling and
+	// ExponentialBackoffPolling functions return some built-in strategies, while the LongPolling function returns the
+	// options which leave the waiting to the Verifalia API; if not specified, the strategy returned by EtaPolling(0, 0)
+	// is used.
 	WaitForNextPoll func(overview Overview, ctx context.Context) error
 
 	// Defines how much time to ask the Verifalia API to wait for the completion of the job on the server side, while polling
 	// for the job.
 	PollWaitTime time.Duration
 
-	// TODO: Progress reporting
+	// An optional notifier of the completion of the jobs, usually fed by the completion callbacks (see the
+	// callback.Dispatcher type): if specified, the waiting process is resumed as soon as the completion of the job is
+	// notified, while polling the job at a longer interval (see FallbackPollInterval) as a safety net.
+	CompletionNotifier CompletionNotifier
+
+	// The interval between two subsequent polls of the job while waiting for its completion to be notified by the
+	// CompletionNotifier; defaults to one minute. Ignored if WaitForNextPoll is specified, or if there is no
+	// CompletionNotifier.
+	FallbackPollInterval time.Duration
+
+	// An optional function which is invoked after each poll of the job, with its updated progress.
+	ProgressHandler func(update ProgressUpdate)
+}
+
+// ProgressUpdate contains the progress of an email validation job, as reported to WaitingOptions.ProgressHandler.
+type ProgressUpdate struct {
+	// The updated overview of the job.
+	Overview Overview
+
+	// The eventual completion progress of the job, with its percentage and estimated time remaining; nil if the
+	// Verifalia API does not report it (for example, for the jobs which are already completed).
+	Progress *Progress
+
+	// The processing status of the job. The JobStatus enum-like object contains the supported values, for example:
+	// JobStatus.Completed
+	Status string
+
+	// The processing status of the job before this poll; differs from Status if the job changed its status since the
+	// previous poll.
+	PreviousStatus string
+
+	// The time elapsed since the waiting process started.
+	Elapsed time.Duration
+}
+
+// CompletionNotifier notifies the completion of email validation jobs. Implementations must be safe for concurrent use
+// by multiple goroutines.
+type CompletionNotifier interface {
+	// Subscribe returns a channel which is closed once the completion of the specified job is notified, along with a
+	// function which releases the subscription. Should the completion of the job have already been notified, the
+	// returned channel is closed already.
+	Subscribe(jobId string) (<-chan struct{}, func())
 }
 
+const defaultFallbackPollInterval = time.Minute
+
 // WaitForCompletion sleeps until the e-mail verification job completes.
+// Should the job be deleted or expire while waiting, the returned error matches rest.ErrGone (through errors.Is).
 func (client *Client) WaitForCompletion(validation *Job) (result *Job, err error) {
 	return client.WaitForCompletionWithOptions(validation, nil)
 }
 
 // WaitForCompletionWithOptions sleeps until the e-mail verification job completes.
+// Should the job be deleted or expire while waiting, the returned error matches rest.ErrGone (through errors.Is).
 func (client *Client) WaitForCompletionWithOptions(validation *Job, options *WaitingOptions) (current *Job, err error) {
+	if validation == nil {
+		return nil, errors.New("the job to wait for can't be nil")
+	}
+
 	var ctx context.Context
 	current = validation
+	startedOn := time.Now()
 
 	if options != nil {
 		ctx = options.Context
@@ -71,11 +125,31 @@ func (client *Client) WaitForCompletionWithOptions(validation *Job, options *Wai
 	}
 
 	var retrievalOptions *RetrievalOptions
+	waitForNextPoll := defaultWaitForNextPoll
 
 	if options != nil {
-		retrievalOptions = &RetrievalOptions{RetrievalWaitTime: options.PollWaitTime}
+		retrievalOptions = &RetrievalOptions{Context: ctx, RetrievalWaitTime: options.PollWaitTime}
+
+		if options.WaitForNextPoll != nil {
+			waitForNextPoll = options.WaitForNextPoll
+		}
+
+		if options.CompletionNotifier != nil && current.Overview.Status == JobStatus.InProgress {
+			if options.WaitForNextPoll == nil {
+				waitForNextPoll = fallbackWaitForNextPoll(options.FallbackPollInterval)
+			}
+
+			notification, unsubscribe := options.CompletionNotifier.Subscribe(current.Overview.Id)
+			defer unsubscribe()
+
+			waitForNextPoll = waitForNotification(notification, waitForNextPoll)
+		}
 	}
 
+	// The polling strategies keep the state of this waiting process in the context they receive
+
+	pollCtx := context.WithValue(ctx, pollingStateKey{}, &pollingState{})
+
 	for {
 		if current.Overview.Status != JobStatus.InProgress {
 			break
@@ -83,11 +157,7 @@ func (client *Client) WaitForCompletionWithOptions(validation *Job, options *Wai
 
 		// Wait for the polling interval (or the context cancellation)
 
-		if options != nil && options.WaitForNextPoll != nil {
-			err = options.WaitForNextPoll(current.Overview, ctx)
-		} else {
-			err = defaultWaitForNextPoll(current.Overview, ctx)
-		}
+		err = waitForNextPoll(current.Overview, pollCtx)
 
 		if err != nil {
 			return nil, err
@@ -95,27 +165,66 @@ func (client *Client) WaitForCompletionWithOptions(validation *Job, options *Wai
 
 		// Retrieve the updated job
 
-		current, err = client.GetWithOptions(current.Overview.Id, retrievalOptions)
+		previousStatus := current.Overview.Status
+		current, err = client.get(ctx, current.Overview.Id, retrievalOptions)
 
 		if err != nil {
 			return nil, err
 		}
+
+		if options != nil && options.ProgressHandler != nil {
+			options.ProgressHandler(ProgressUpdate{
+				Overview:       current.Overview,
+				Progress:       current.Overview.Progress,
+				Status:         current.Overview.Status,
+				PreviousStatus: previousStatus,
+				Elapsed:        time.Since(startedOn),
+			})
+		}
 	}
 
 	return current, nil
 }
 
-func defaultWaitForNextPoll(overview Overview, ctx context.Context) error {
-	// TODO: observe the job ETA if we have one
-
-	// Either wait for the polling interval or until the context is cancelled
+func fallbackWaitForNextPoll(interval time.Duration) func(overview Overview, ctx context.Context) error {
+	if interval <= 0 {
+		interval = defaultFallbackPollInterval
+	}
 
-	select {
-	case <-time.After(5 * time.Second):
-	case <-ctx.Done():
+	return func(overview Overview, ctx context.Context) error {
+		return sleepUntilNextPoll(ctx, interval)
 	}
+}
 
-	// If the context is cancelled, let the caller know
+// waitForNotification returns a waiting function which races the specified one against the notification of the
+// completion of the job; once notified, the subsequent polls are paced by the specified function only.
+func waitForNotification(notification <-chan struct{}, waitForNextPoll func(overview Overview, ctx context.Context) error) func(overview Overview, ctx context.Context) error {
+	return func(overview Overview, ctx context.Context) error {
+		if notification == nil {
+			return waitForNextPoll(overview, ctx)
+		}
+
+		waitCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		done := make(chan error, 1)
+
+		go func() {
+			done <- waitForNextPoll(overview, waitCtx)
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-notification:
+			notification = nil
 
-	return ctx.Err()
+			// Stop the waiting function and poll right away, unless the caller context is done
+
+			cancel()
+			<-done
+
+			return ctx.Err()
+		}
+	}
 }
@@ -0,0 +1,74 @@
+package verifalia
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+)
+
+// APIError is returned when the Verifalia API replies with an unexpected HTTP status code; it carries the status
+// code, the invoked endpoint, the request ID and the parsed problem details, if any. Use errors.As to inspect it:
+//  var apiError *verifalia.APIError
+//  if errors.As(err, &apiError) { ... }
+type APIError = rest.APIError
+
+// ProblemDetails contains the machine-readable details of an error returned by the Verifalia API.
+type ProblemDetails = rest.ProblemDetails
+
+// EndpointsUnreachableError is returned when none of the Verifalia API endpoints could be reached, and contains the
+// error which occurred for each of them.
+type EndpointsUnreachableError = rest.EndpointsUnreachableError
+
+// Sentinel errors which can be matched against the errors returned by the client through errors.Is, for example:
+//  if errors.Is(err, verifalia.ErrInsufficientCredits) { ... }
+var (
+	// The Verifalia API can't authenticate the request (HTTP status code 401).
+	ErrAuthenticationFailed = rest.ErrAuthenticationFailed
+
+	// The user lacks the permissions needed to perform the request (HTTP status code 403).
+	ErrAuthorizationFailed = rest.ErrAuthorizationFailed
+
+	// The Verifalia account does not have enough credits (HTTP status code 402).
+	ErrInsufficientCredits = rest.ErrInsufficientCredits
+
+	// The requested resource does not exist (HTTP status code 404).
+	ErrNotFound = rest.ErrNotFound
+
+	// The requested resource has been deleted or is expired (HTTP status code 410).
+	ErrGone = rest.ErrGone
+
+	// The request has been throttled (HTTP status code 429).
+	ErrRateLimited = rest.ErrRateLimited
+
+	// None of the Verifalia API endpoints could be reached.
+	ErrAllEndpointsUnreachable = rest.ErrAllEndpointsUnreachable
+)
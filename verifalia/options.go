@@ -0,0 +1,197 @@
+package verifalia
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/rest"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures an optional setting of a Client created through NewClientWithOptions.
+type Option func(settings *clientSettings)
+
+type clientSettings struct {
+	httpClient      *http.Client
+	transport       http.RoundTripper
+	timeout         *time.Duration
+	baseUrls        []string
+	proxy           func(*http.Request) (*url.URL, error)
+	tlsConfig       *tls.Config
+	userAgentSuffix string
+	restOptions     []rest.ClientOption
+}
+
+// WithHTTPClient makes the client send its requests (including the ones the bearer authentication provider sends to
+// acquire its tokens) through the specified *http.Client, in place of the one built by the authentication provider.
+// When authenticating through a client certificate, the transport of the specified client must present that
+// certificate.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(settings *clientSettings) {
+		settings.httpClient = httpClient
+	}
+}
+
+// WithTransport makes the client send its requests through the specified http.RoundTripper.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(settings *clientSettings) {
+		settings.transport = transport
+	}
+}
+
+// WithTimeout sets the maximum time each single HTTP request sent to the Verifalia API can take, including the
+// reading of its response; the default is 30 seconds. The requests which ask the API to wait for the completion of a
+// job (see WaitingOptions.PollWaitTime) are given that wait time on top of the timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(settings *clientSettings) {
+		settings.timeout = &timeout
+	}
+}
+
+// WithOperationTimeout sets the maximum time each operation (for example, a job submission) can take as a whole,
+// including all its retry attempts against the different API endpoints.
+func WithOperationTimeout(timeout time.Duration) Option {
+	return func(settings *clientSettings) {
+		settings.restOptions = append(settings.restOptions, rest.WithOperationTimeout(timeout))
+	}
+}
+
+// WithBaseUrls makes the client use the specified base URLs in place of the standard Verifalia API endpoints, for
+// example to point it at a staging environment or at a local stand-in; the bearer authentication provider acquires its
+// tokens from these base URLs as well.
+func WithBaseUrls(baseUrls ...string) Option {
+	return func(settings *clientSettings) {
+		settings.baseUrls = baseUrls
+	}
+}
+
+// WithProxy sets the function which returns the proxy to use for each request; see http.Transport.Proxy.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(settings *clientSettings) {
+		settings.proxy = proxy
+	}
+}
+
+// WithProxyUrl makes the client send all its requests through the proxy at the specified URL.
+func WithProxyUrl(proxyUrl *url.URL) Option {
+	return WithProxy(http.ProxyURL(proxyUrl))
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the Verifalia API. When authenticating through a
+// client certificate, the certificate is added to the specified configuration unless it already has one.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(settings *clientSettings) {
+		settings.tlsConfig = tlsConfig
+	}
+}
+
+// WithUserAgentSuffix appends the specified string to the user agent sent to the Verifalia API, allowing to
+// identify the calling application, for example: WithUserAgentSuffix("acme-crm/1.2").
+func WithUserAgentSuffix(suffix string) Option {
+	return func(settings *clientSettings) {
+		settings.userAgentSuffix = suffix
+	}
+}
+
+// WithRetryPolicy sets the policy which decides whether and when failed requests are retried; the default is
+// rest.DefaultRetryPolicy.
+func WithRetryPolicy(policy rest.RetryPolicy) Option {
+	return func(settings *clientSettings) {
+		settings.restOptions = append(settings.restOptions, rest.WithRetryPolicy(policy))
+	}
+}
+
+// WithCircuitBreaker configures how the health of the API endpoints is tracked; the default is
+// rest.DefaultCircuitBreakerOptions, which also provides the value of each zero field of the specified options.
+func WithCircuitBreaker(options rest.CircuitBreakerOptions) Option {
+	return func(settings *clientSettings) {
+		settings.restOptions = append(settings.restOptions, rest.WithCircuitBreaker(options))
+	}
+}
+
+// buildHttpClient returns the HTTP client configured by the settings, starting from the specified one.
+func (settings *clientSettings) buildHttpClient(baseClient *http.Client) (*http.Client, error) {
+	if settings.httpClient != nil {
+		baseClient = settings.httpClient
+	}
+
+	if settings.transport == nil && settings.timeout == nil && settings.proxy == nil && settings.tlsConfig == nil {
+		return baseClient, nil
+	}
+
+	httpClient := *baseClient
+
+	if settings.transport != nil {
+		httpClient.Transport = settings.transport
+	}
+
+	if settings.timeout != nil {
+		httpClient.Timeout = *settings.timeout
+	}
+
+	if settings.proxy != nil || settings.tlsConfig != nil {
+		baseTransport := httpClient.Transport
+
+		if baseTransport == nil {
+			baseTransport = http.DefaultTransport
+		}
+
+		transport, ok := baseTransport.(*http.Transport)
+
+		if !ok {
+			return nil, errors.New("proxy and TLS settings require the transport to be an *http.Transport")
+		}
+
+		transport = transport.Clone()
+
+		if settings.proxy != nil {
+			transport.Proxy = settings.proxy
+		}
+
+		if settings.tlsConfig != nil {
+			tlsConfig := settings.tlsConfig.Clone()
+
+			if len(tlsConfig.Certificates) == 0 && transport.TLSClientConfig != nil {
+				tlsConfig.Certificates = transport.TLSClientConfig.Certificates
+			}
+
+			transport.TLSClientConfig = tlsConfig
+		}
+
+		httpClient.Transport = transport
+	}
+
+	return &httpClient, nil
+}
@@ -0,0 +1,261 @@
+package rest
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrAuthenticationFailed is matched (through errors.Is) when the Verifalia API can't authenticate the request
+// (HTTP status code 401), usually because of invalid or expired credentials, or when the authentication provider
+// can't authenticate the request because its credentials have been rejected (see auth.ErrAuthenticationFailed).
+var ErrAuthenticationFailed = auth.ErrAuthenticationFailed
+
+// ErrAuthorizationFailed is matched (through errors.Is) when the Verifalia API authenticates the request but the
+// user lacks the permissions needed to perform it (HTTP status code 403).
+var ErrAuthorizationFailed = errors.New("the provided credential is not authorized to perform the requested operation")
+
+// ErrInsufficientCredits is matched (through errors.Is) when the Verifalia account does not have enough credits to
+// perform the requested operation (HTTP status code 402).
+var ErrInsufficientCredits = errors.New("insufficient credits to perform the requested operation")
+
+// ErrNotFound is matched (through errors.Is) when the requested resource does not exist (HTTP status code 404).
+var ErrNotFound = errors.New("the requested resource could not be found")
+
+// ErrGone is matched (through errors.Is) when the requested resource existed but has been deleted or is expired
+// (HTTP status code 410).
+var ErrGone = errors.New("the requested resource has been deleted or is expired")
+
+// ErrRateLimited is matched (through errors.Is) when the Verifalia API throttled the request (HTTP status code 429).
+var ErrRateLimited = errors.New("too many requests, the request has been throttled")
+
+// ErrAllEndpointsUnreachable is matched (through errors.Is) when none of the Verifalia API endpoints could be reached;
+// requests cancelled by their context, or exceeding its deadline, fail with the error of the context instead.
+var ErrAllEndpointsUnreachable = errors.New("all the base URIs are unreachable")
+
+// The maximum number of bytes of an error response body which are read and kept.
+const maxErrorBodySize = 64 * 1024
+
+// ProblemDetails contains the machine-readable details of an error returned by the Verifalia API, in the
+// application/problem+json format (RFC 7807).
+type ProblemDetails struct {
+	// A URI reference which identifies the problem type.
+	Type string `json:"type"`
+
+	// A short, human-readable summary of the problem type.
+	Title string `json:"title"`
+
+	// The HTTP status code generated by the API for this occurrence of the problem.
+	Status int `json:"status"`
+
+	// A human-readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail"`
+
+	// A URI reference which identifies the specific occurrence of the problem.
+	Instance string `json:"instance"`
+
+	// Any additional member of the problem details object, for example the trace identifier of the request.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// APIError is returned when the Verifalia API replies with an unexpected HTTP status code. Use errors.Is to check
+// it against the sentinel errors of this package, for example:
+//  if errors.Is(err, rest.ErrInsufficientCredits) { ... }
+type APIError struct {
+	// The HTTP status code returned by the API.
+	StatusCode int
+
+	// The HTTP method of the failed request.
+	Method string
+
+	// The URL of the API endpoint which returned the error.
+	Endpoint string
+
+	// The identifier the API assigned to the request, if any; useful while contacting the Verifalia support.
+	RequestId string
+
+	// The parsed problem details returned by the API, if any.
+	Problem *ProblemDetails
+
+	// The (eventually truncated) response body.
+	Body string
+}
+
+func (err *APIError) Error() string {
+	message := fmt.Sprintf("unexpected HTTP response: %d", err.StatusCode)
+
+	if sentinel := sentinelOf(err.StatusCode); sentinel != nil {
+		message = fmt.Sprintf("%v (HTTP status code: %d)", sentinel, err.StatusCode)
+	}
+
+	if err.Problem != nil {
+		if err.Problem.Title != "" {
+			message = fmt.Sprintf("%s: %s", message, err.Problem.Title)
+		}
+
+		if err.Problem.Detail != "" {
+			message = fmt.Sprintf("%s: %s", message, err.Problem.Detail)
+		}
+	}
+
+	if err.RequestId != "" {
+		message = fmt.Sprintf("%s (request ID: %s)", message, err.RequestId)
+	}
+
+	return message
+}
+
+// Is allows errors.Is to match an APIError against the sentinel error for its HTTP status code.
+func (err *APIError) Is(target error) bool {
+	sentinel := sentinelOf(err.StatusCode)
+	return sentinel != nil && sentinel == target
+}
+
+func sentinelOf(statusCode int) error {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return ErrAuthenticationFailed
+	case http.StatusPaymentRequired:
+		return ErrInsufficientCredits
+	case http.StatusForbidden:
+		return ErrAuthorizationFailed
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusGone:
+		return ErrGone
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+
+	return nil
+}
+
+// NewAPIError builds an APIError out of an unexpected response of the Verifalia API, parsing its problem details,
+// if any; the response body is consumed and closed.
+func NewAPIError(response *http.Response) *APIError {
+	defer func() { _ = response.Body.Close() }()
+
+	apiError := &APIError{
+		StatusCode: response.StatusCode,
+		RequestId:  response.Header.Get("X-Request-Id"),
+	}
+
+	if response.Request != nil {
+		apiError.Method = response.Request.Method
+		apiError.Endpoint = response.Request.URL.String()
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+
+	if err != nil {
+		return apiError
+	}
+
+	apiError.Body = string(data)
+
+	// Parse the eventual problem details
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+
+	if mediaType == "application/problem+json" || (strings.HasSuffix(mediaType, "json") && len(data) > 0) {
+		var problem ProblemDetails
+		var members map[string]interface{}
+
+		if json.Unmarshal(data, &problem) == nil && json.Unmarshal(data, &members) == nil &&
+			(problem.Title != "" || problem.Detail != "" || problem.Type != "") {
+			for _, name := range []string{"type", "title", "status", "detail", "instance"} {
+				delete(members, name)
+			}
+
+			if len(members) > 0 {
+				problem.Extensions = members
+			}
+
+			apiError.Problem = &problem
+
+			if traceId, ok := members["traceId"].(string); ok && apiError.RequestId == "" {
+				apiError.RequestId = traceId
+			}
+		}
+	}
+
+	return apiError
+}
+
+// EndpointError is the error which occurred while invoking a single API endpoint.
+type EndpointError struct {
+	// The URL of the invoked endpoint.
+	Url string
+
+	// The error which occurred.
+	Err error
+}
+
+// EndpointsUnreachableError is returned when none of the Verifalia API endpoints could be reached; it matches
+// ErrAllEndpointsUnreachable through errors.Is.
+type EndpointsUnreachableError struct {
+	// The errors which occurred while invoking each endpoint, in chronological order.
+	Errors []EndpointError
+}
+
+func (err *EndpointsUnreachableError) Error() string {
+	message := "All the base URIs are unreachable."
+
+	for _, endpointError := range err.Errors {
+		message = fmt.Sprintf("%v\n%v => %v", message, endpointError.Url, endpointError.Err)
+	}
+
+	return message
+}
+
+// Is allows errors.Is to match the error against ErrAllEndpointsUnreachable.
+func (err *EndpointsUnreachableError) Is(target error) bool {
+	return target == ErrAllEndpointsUnreachable
+}
+
+// Unwrap returns the errors which occurred while invoking each endpoint.
+func (err *EndpointsUnreachableError) Unwrap() []error {
+	errs := make([]error, len(err.Errors))
+
+	for i, endpointError := range err.Errors {
+		errs[i] = endpointError.Err
+	}
+
+	return errs
+}
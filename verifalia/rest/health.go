@@ -0,0 +1,348 @@
+package rest
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker which guards an API endpoint.
+type CircuitState int
+
+const (
+	// CircuitClosed means the endpoint is healthy and takes part in the rotation.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen means the endpoint failed repeatedly and is used only as a last resort, until its cooldown elapses.
+	CircuitOpen
+
+	// CircuitHalfOpen means the cooldown of the endpoint elapsed and the next request sent to it acts as a probe:
+	// a success closes the circuit, while a failure opens it again.
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	}
+
+	return "Unknown"
+}
+
+// EndpointHealth is a snapshot of the health of an API endpoint.
+type EndpointHealth struct {
+	// The base URL of the endpoint.
+	BaseUrl string
+
+	// The state of the circuit breaker which guards the endpoint.
+	State CircuitState
+
+	// The number of consecutive failed requests (transport errors or HTTP 5xx status codes).
+	ConsecutiveFailures int
+
+	// The exponentially weighted moving average of the response time of the endpoint, or zero if the endpoint
+	// did not reply yet; the requests the API holds on purpose (with a waitTime) are not taken into account.
+	Latency time.Duration
+
+	// The time the circuit breaker will allow probing the endpoint again, while its circuit is open.
+	OpenUntil time.Time
+}
+
+// CircuitBreakerOptions configures how the REST client tracks the health of the API endpoints; zero fields are replaced
+// by the ones of DefaultCircuitBreakerOptions.
+type CircuitBreakerOptions struct {
+	// The number of consecutive failures after which the circuit of an endpoint opens.
+	FailureThreshold int
+
+	// How long an endpoint with an open circuit is avoided before being probed again; slow endpoints get a request
+	// once per cooldown as well, so that their latency can recover.
+	Cooldown time.Duration
+
+	// The weight, ranging from 0 to 1, of the last response time in the latency moving average.
+	LatencySmoothing float64
+
+	// Endpoints whose latency exceeds the one of the fastest healthy endpoint by this factor are deprioritized.
+	SlownessFactor float64
+}
+
+// DefaultCircuitBreakerOptions are the CircuitBreakerOptions used by the REST client unless otherwise specified.
+var DefaultCircuitBreakerOptions = CircuitBreakerOptions{
+	FailureThreshold: 3,
+	Cooldown:         30 * time.Second,
+	LatencySmoothing: 0.2,
+	SlownessFactor:   2,
+}
+
+// The minimum latency difference for an endpoint to be deemed slower than another one.
+const minSlownessGap = 50 * time.Millisecond
+
+type endpointOutcome int
+
+const (
+	endpointSucceeded endpointOutcome = iota
+	endpointFailed
+	// The attempt says nothing about the endpoint health, for example because the caller cancelled it.
+	endpointUndetermined
+)
+
+type endpointState struct {
+	baseUrl             string
+	state               CircuitState
+	consecutiveFailures int
+	latency             time.Duration
+	openUntil           time.Time
+	probing             bool
+
+	// The last time the latency of the endpoint has been sampled (or a request has been routed to it for that purpose).
+	sampledOn time.Time
+}
+
+type endpointTracker struct {
+	mutex     sync.Mutex
+	options   CircuitBreakerOptions
+	endpoints []*endpointState
+	now       func() time.Time
+}
+
+// withDefaults returns a copy of the options where each zero field is replaced by the default one.
+func (options CircuitBreakerOptions) withDefaults() CircuitBreakerOptions {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = DefaultCircuitBreakerOptions.FailureThreshold
+	}
+
+	if options.Cooldown <= 0 {
+		options.Cooldown = DefaultCircuitBreakerOptions.Cooldown
+	}
+
+	if options.LatencySmoothing <= 0 || options.LatencySmoothing > 1 {
+		options.LatencySmoothing = DefaultCircuitBreakerOptions.LatencySmoothing
+	}
+
+	if options.SlownessFactor <= 0 {
+		options.SlownessFactor = DefaultCircuitBreakerOptions.SlownessFactor
+	}
+
+	return options
+}
+
+func newEndpointTracker(baseUrls []string, options CircuitBreakerOptions) *endpointTracker {
+	tracker := &endpointTracker{
+		options: options.withDefaults(),
+		now:     time.Now,
+	}
+
+	for _, baseUrl := range baseUrls {
+		tracker.endpoints = append(tracker.endpoints, &endpointState{
+			baseUrl: baseUrl,
+			state:   CircuitClosed,
+		})
+	}
+
+	return tracker
+}
+
+// acquire selects the endpoint for the next attempt, skipping the ones already tried (unless all of them were).
+// Healthy endpoints come first, in round-robin order according to the specified offset, with the slow ones after
+// the fast ones; then come the endpoints whose cooldown elapsed, which are probed one request at a time; endpoints
+// with an open circuit (or being probed) are used only as a last resort. Slow endpoints get a request again once per
+// cooldown, so that their latency can recover.
+func (tracker *endpointTracker) acquire(offset uint64, tried []bool) int {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	now := tracker.now()
+	count := len(tracker.endpoints)
+	allTried := true
+
+	for _, t := range tried {
+		allTried = allTried && t
+	}
+
+	var fastestLatency time.Duration
+
+	for _, endpoint := range tracker.endpoints {
+		if endpoint.state == CircuitClosed && endpoint.latency > 0 && (fastestLatency == 0 || endpoint.latency < fastestLatency) {
+			fastestLatency = endpoint.latency
+		}
+	}
+
+	rank := func(idx int) int {
+		endpoint := tracker.endpoints[idx]
+
+		switch {
+		case endpoint.state == CircuitClosed && !tracker.isSlow(endpoint, fastestLatency):
+			return 0
+		case endpoint.state == CircuitClosed:
+			return 1
+		case !endpoint.probing && !now.Before(endpoint.openUntil):
+			return 2
+		}
+
+		return 3
+	}
+
+	// Group the candidates by rank, in the order of the configured endpoints
+
+	var groups [4][]int
+
+	for idx := 0; idx < count; idx++ {
+		if !allTried && tried[idx] {
+			continue
+		}
+
+		endpointRank := rank(idx)
+
+		// A slow endpoint which did not reply for a whole cooldown is sampled again, ahead of the fast ones
+
+		if endpointRank == 1 && now.Sub(tracker.endpoints[idx].sampledOn) >= tracker.options.Cooldown {
+			tracker.endpoints[idx].sampledOn = now
+			return idx
+		}
+
+		groups[endpointRank] = append(groups[endpointRank], idx)
+	}
+
+	var selected int
+
+	for endpointRank, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		// The round-robin offset spreads the requests evenly across the endpoints of the same rank
+
+		selected = group[offset%uint64(len(group))]
+
+		if endpointRank == 3 {
+			// Last resort: the endpoint whose cooldown is closer to the end, leaving alone the ones being probed
+
+			for _, idx := range group {
+				endpoint, selectedEndpoint := tracker.endpoints[idx], tracker.endpoints[selected]
+
+				if endpoint.probing != selectedEndpoint.probing {
+					if !endpoint.probing {
+						selected = idx
+					}
+				} else if endpoint.openUntil.Before(selectedEndpoint.openUntil) {
+					selected = idx
+				}
+			}
+		}
+
+		break
+	}
+
+	endpoint := tracker.endpoints[selected]
+
+	if endpoint.state != CircuitClosed && !endpoint.probing && !now.Before(endpoint.openUntil) {
+		endpoint.state = CircuitHalfOpen
+		endpoint.probing = true
+	}
+
+	return selected
+}
+
+func (tracker *endpointTracker) isSlow(endpoint *endpointState, fastestLatency time.Duration) bool {
+	if endpoint.latency == 0 || fastestLatency == 0 {
+		return false
+	}
+
+	return endpoint.latency-fastestLatency > minSlownessGap &&
+		float64(endpoint.latency) > float64(fastestLatency)*tracker.options.SlownessFactor
+}
+
+// report updates the health of the specified endpoint with the outcome of an attempt.
+func (tracker *endpointTracker) report(idx int, latency time.Duration, outcome endpointOutcome) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	endpoint := tracker.endpoints[idx]
+	endpoint.probing = false
+
+	if latency > 0 && outcome != endpointUndetermined {
+		endpoint.sampledOn = tracker.now()
+
+		if endpoint.latency == 0 {
+			endpoint.latency = latency
+		} else {
+			alpha := tracker.options.LatencySmoothing
+			endpoint.latency = time.Duration(alpha*float64(latency) + (1-alpha)*float64(endpoint.latency))
+		}
+	}
+
+	switch outcome {
+	case endpointSucceeded:
+		endpoint.state = CircuitClosed
+		endpoint.consecutiveFailures = 0
+		endpoint.openUntil = time.Time{}
+
+	case endpointFailed:
+		endpoint.consecutiveFailures++
+
+		if endpoint.state == CircuitHalfOpen || endpoint.consecutiveFailures >= tracker.options.FailureThreshold {
+			endpoint.state = CircuitOpen
+			endpoint.openUntil = tracker.now().Add(tracker.options.Cooldown)
+		}
+	}
+}
+
+func (tracker *endpointTracker) snapshot() []EndpointHealth {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	now := tracker.now()
+	result := make([]EndpointHealth, len(tracker.endpoints))
+
+	for i, endpoint := range tracker.endpoints {
+		state := endpoint.state
+
+		if state == CircuitOpen && !now.Before(endpoint.openUntil) {
+			state = CircuitHalfOpen
+		}
+
+		result[i] = EndpointHealth{
+			BaseUrl:             endpoint.baseUrl,
+			State:               state,
+			ConsecutiveFailures: endpoint.consecutiveFailures,
+			Latency:             endpoint.latency,
+			OpenUntil:           endpoint.openUntil,
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestEndpointTracker creates a tracker whose clock is controlled by the returned function, which advances it.
+func newTestEndpointTracker(count int, options CircuitBreakerOptions) (*endpointTracker, func(time.Duration)) {
+	baseUrls := make([]string, count)
+
+	for i := range baseUrls {
+		baseUrls[i] = "https://api-" + string(rune('1'+i)) + ".example.com"
+	}
+
+	now := time.Date(2024, 1, 18, 10, 0, 0, 0, time.UTC)
+	tracker := newEndpointTracker(baseUrls, options)
+	tracker.now = func() time.Time { return now }
+
+	return tracker, func(duration time.Duration) { now = now.Add(duration) }
+}
+
+func TestEndpointTrackerCooldown(t *testing.T) {
+	tracker, advance := newTestEndpointTracker(2, CircuitBreakerOptions{FailureThreshold: 1, Cooldown: time.Minute})
+
+	tracker.report(0, 0, endpointFailed)
+
+	if selected := tracker.acquire(0, make([]bool, 2)); selected != 1 {
+		t.Fatalf("an endpoint with an open circuit has been selected: %v", selected)
+	}
+
+	tracker.report(1, 0, endpointSucceeded)
+
+	// The endpoint can be probed again once its cooldown elapses
+
+	advance(time.Minute)
+
+	if state := tracker.snapshot()[0].State; state != CircuitHalfOpen {
+		t.Fatalf("unexpected state after the cooldown: %v", state)
+	}
+
+	if selected := tracker.acquire(1, []bool{false, true}); selected != 0 {
+		t.Fatalf("the endpoint has not been probed after the cooldown: %v", selected)
+	}
+
+	tracker.report(0, 10*time.Millisecond, endpointSucceeded)
+
+	if health := tracker.snapshot()[0]; health.State != CircuitClosed || health.ConsecutiveFailures != 0 {
+		t.Fatalf("unexpected health after a successful probe: %+v", health)
+	}
+}
+
+func TestEndpointTrackerProbing(t *testing.T) {
+	tracker, advance := newTestEndpointTracker(2, CircuitBreakerOptions{FailureThreshold: 1, Cooldown: time.Minute})
+
+	tracker.report(0, 0, endpointFailed)
+	advance(30 * time.Second)
+	tracker.report(1, 0, endpointFailed)
+	advance(30 * time.Second)
+
+	// The cooldown of the first endpoint elapsed: only one request at a time probes it, while the other ones use the
+	// endpoints with an open circuit as a last resort
+
+	if selected := tracker.acquire(0, make([]bool, 2)); selected != 0 {
+		t.Fatalf("the endpoint has not been probed after the cooldown: %v", selected)
+	}
+
+	for i := 0; i < 3; i++ {
+		if selected := tracker.acquire(0, make([]bool, 2)); selected != 1 {
+			t.Fatalf("the endpoint has been probed by more than one request at a time: %v", selected)
+		}
+	}
+
+	// A failed probe opens the circuit again, for another cooldown
+
+	tracker.report(0, 0, endpointFailed)
+
+	if health := tracker.snapshot()[0]; health.State != CircuitOpen || !health.OpenUntil.Equal(tracker.now().Add(time.Minute)) {
+		t.Fatalf("unexpected health after a failed probe: %+v", health)
+	}
+}
+
+func TestEndpointTrackerLatency(t *testing.T) {
+	tracker, _ := newTestEndpointTracker(3, CircuitBreakerOptions{})
+
+	tracker.report(0, 500*time.Millisecond, endpointSucceeded)
+	tracker.report(1, 10*time.Millisecond, endpointSucceeded)
+	tracker.report(2, 20*time.Millisecond, endpointSucceeded)
+
+	// Slow endpoints are ranked after the fast ones, whatever the round-robin offset, yet they are still used once
+	// the fast ones have been tried
+
+	for offset := uint64(0); offset < 3; offset++ {
+		if selected := tracker.acquire(offset, make([]bool, 3)); selected == 0 {
+			t.Fatalf("the slow endpoint has been selected with offset %v", offset)
+		}
+	}
+
+	if selected := tracker.acquire(0, []bool{false, true, true}); selected != 0 {
+		t.Fatalf("the slow endpoint has not been selected as a fallback: %v", selected)
+	}
+
+	// The latency is a moving average of the response times, using the default smoothing
+
+	tracker.report(1, 110*time.Millisecond, endpointSucceeded)
+
+	if latency := tracker.snapshot()[1].Latency; latency != 30*time.Millisecond {
+		t.Fatalf("unexpected latency moving average: %v", latency)
+	}
+}
+
+func TestEndpointTrackerSlowEndpointRecovery(t *testing.T) {
+	tracker, advance := newTestEndpointTracker(3, CircuitBreakerOptions{Cooldown: time.Minute})
+
+	tracker.report(0, 500*time.Millisecond, endpointSucceeded)
+	tracker.report(1, 150*time.Millisecond, endpointSucceeded)
+	tracker.report(2, 150*time.Millisecond, endpointSucceeded)
+
+	// The share of the slow endpoint is spread evenly across the fast ones
+
+	selections := make([]int, 3)
+
+	for offset := uint64(0); offset < 1000; offset++ {
+		selections[tracker.acquire(offset, make([]bool, 3))]++
+	}
+
+	if selections[0] != 0 || selections[1] != 500 || selections[2] != 500 {
+		t.Fatalf("unexpected selections: %v", selections)
+	}
+
+	// Once per cooldown, the slow endpoint gets a request again, until its latency recovers
+
+	for i := 0; i < 20 && tracker.snapshot()[0].Latency > 300*time.Millisecond; i++ {
+		advance(time.Minute)
+
+		if selected := tracker.acquire(0, make([]bool, 3)); selected != 0 {
+			t.Fatalf("the slow endpoint has not been sampled again after the cooldown: %v", selected)
+		}
+
+		if selected := tracker.acquire(0, make([]bool, 3)); selected == 0 {
+			t.Fatal("the slow endpoint has been sampled more than once per cooldown")
+		}
+
+		tracker.report(0, 150*time.Millisecond, endpointSucceeded)
+	}
+
+	selections = make([]int, 3)
+
+	for offset := uint64(0); offset < 300; offset++ {
+		selections[tracker.acquire(offset, make([]bool, 3))]++
+	}
+
+	if selections[0] != 100 || selections[1] != 100 || selections[2] != 100 {
+		t.Fatalf("the recovered endpoint does not get its share of the requests: %v", selections)
+	}
+}
@@ -32,13 +32,18 @@ package rest
  */
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/verifalia/verifalia-go-sdk/verifalia/auth"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 // BaseUrls contain the standard base URLs for the Verifalia API.
@@ -79,149 +84,539 @@ var ContentType = struct {
 }
 
 type multiplexedRestClient struct {
+	// Accessed atomically: kept first to guarantee its 64-bit alignment on 32-bit platforms
+	currentBaseUrlIdx uint64
+
 	userAgent              string
 	underlyingClient       *http.Client
 	authenticationProvider auth.Provider
-	currentBaseUrlIdx      int
 	baseUrls               []string
+	retryPolicy            RetryPolicy
+	endpoints              *endpointTracker
+	operationTimeout       time.Duration
 }
 
 type InvocationOptions struct {
 	Method      string
 	Resource    string
 	QueryParams url.Values
-	Body        io.Reader
-	Context     context.Context
-	Headers     map[string]string
+
+	// The request body. Since the same request may be sent multiple times (to different endpoints, or after a
+	// re-authentication), the body is replayed on each attempt: readers which also implement io.ReaderAt and
+	// io.Seeker (e.g. *bytes.Reader, *strings.Reader, *os.File) are replayed from their current position without
+	// being copied, while any other reader is buffered in memory first. Ignored if GetBody is set.
+	Body io.Reader
+
+	// An optional factory which returns a new reader over the whole request body, invoked once for each attempt.
+	GetBody func() (io.Reader, error)
+
+	Context context.Context
+	Headers map[string]string
+
+	// If positive, replaces the timeout of the HTTP client for this request, which also covers the reading of the
+	// response body; a negative value disables the timeout altogether. The operation timeout of the REST client, if
+	// any, still applies.
+	Timeout time.Duration
 }
 
+// Client invokes the Verifalia API. Implementations must be safe for concurrent use by multiple goroutines.
 type Client interface {
 	Invoke(options InvocationOptions) (*http.Response, error)
 }
 
-func NewMultiplexedRestClient(authenticationProvider auth.Provider, userAgent string, baseUrls []string) *multiplexedRestClient {
+// HealthReporter is implemented by the REST clients which keep track of the health of their API endpoints.
+type HealthReporter interface {
+	EndpointHealth() []EndpointHealth
+}
+
+// ClientOption configures an optional setting of the REST client created by NewMultiplexedRestClient.
+type ClientOption func(client *multiplexedRestClient)
+
+// WithRetryPolicy sets the policy which decides whether and when failed requests are retried; the default is
+// DefaultRetryPolicy, while a nil policy disables retries altogether.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(client *multiplexedRestClient) {
+		if policy == nil {
+			policy = &ExponentialBackoffRetryPolicy{MaxAttempts: 1}
+		}
+
+		client.retryPolicy = policy
+	}
+}
+
+// WithCircuitBreaker configures how the health of the API endpoints is tracked; the default is
+// DefaultCircuitBreakerOptions, which also provides the value of each zero field of the specified options.
+func WithCircuitBreaker(options CircuitBreakerOptions) ClientOption {
+	return func(client *multiplexedRestClient) {
+		client.endpoints.options = options.withDefaults()
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send the requests, in place of the one built by the authentication
+// provider.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(client *multiplexedRestClient) {
+		client.underlyingClient = httpClient
+	}
+}
+
+// WithOperationTimeout limits the time each invocation can take as a whole, including all its retry attempts, the
+// delays between them and the reading of the response body.
+func WithOperationTimeout(timeout time.Duration) ClientOption {
+	return func(client *multiplexedRestClient) {
+		client.operationTimeout = timeout
+	}
+}
+
+// NewMultiplexedRestClient creates a REST client which distributes the requests across the specified base URLs and
+// fails over to the next one in the event of an error. The returned client is safe for concurrent use by multiple
+// goroutines.
+func NewMultiplexedRestClient(authenticationProvider auth.Provider, userAgent string, baseUrls []string, options ...ClientOption) *multiplexedRestClient {
 	httpClient := authenticationProvider.BuildClient()
 
-	return &multiplexedRestClient{
+	client := &multiplexedRestClient{
 		userAgent:              userAgent,
 		underlyingClient:       httpClient,
 		authenticationProvider: authenticationProvider,
-		currentBaseUrlIdx:      0,
 		baseUrls:               baseUrls,
+		retryPolicy:            DefaultRetryPolicy,
+		endpoints:              newEndpointTracker(baseUrls, DefaultCircuitBreakerOptions),
 	}
+
+	for _, option := range options {
+		option(client)
+	}
+
+	return client
 }
 
 type invocationError struct {
 	url   string
 	error error
+
+	// True if the request could not be sent to the endpoint or its response could not be received.
+	transport bool
+
+	// True if a connection to the endpoint has been established, thus the request may have reached the API.
+	sent bool
 }
 
 func (client *multiplexedRestClient) Invoke(options InvocationOptions) (*http.Response, error) {
-	errs := make([]invocationError, 0)
+	if client.operationTimeout <= 0 {
+		return client.invoke(options)
+	}
 
-	// Performs a maximum of as many attempts as the number of configured base API endpoints, keeping track
-	// of the last used endpoint after each call, in order to try to distribute the load evenly across the
-	// available endpoints.
+	// Bound the whole operation, including the reading of the response body
 
-	for idxAttempt := 0; idxAttempt < len(client.baseUrls); idxAttempt++ {
-		// Retrieve the API base URL
+	parentCtx := options.Context
 
-		baseUrl := client.baseUrls[client.currentBaseUrlIdx%len(client.baseUrls)]
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
 
-		// The next request will be performed on a subsequent API endpoint
+	ctx, cancel := context.WithTimeout(parentCtx, client.operationTimeout)
+	options.Context = ctx
 
-		client.currentBaseUrlIdx++
+	response, err := client.invoke(options)
 
-		// Set up the query string
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 
-		queryString := ""
+	response.Body = &cancelOnCloseBody{
+		ReadCloser: response.Body,
+		cancel:     cancel,
+	}
 
-		if options.QueryParams != nil && len(options.QueryParams) > 0 {
-			queryString = options.QueryParams.Encode()
-		}
+	return response, nil
+}
 
-		// Build the final URL
+func (client *multiplexedRestClient) invoke(options InvocationOptions) (*http.Response, error) {
+	errs := make([]EndpointError, 0)
 
-		finalUrl := fmt.Sprintf("%s/%s?%s", baseUrl, options.Resource, queryString)
+	if len(client.baseUrls) == 0 {
+		return nil, errors.New("no base URL configured")
+	}
 
-		// log.Printf("%v %v (attempt %d)...\n", options.Method, finalUrl, idxAttempt)
+	getBody, err := newBodyFactory(options)
 
-		// Init the HTTP request
+	if err != nil {
+		return nil, err
+	}
 
-		var request *http.Request
-		var err error
+	// Keeps performing attempts, as long as the retry policy allows it, moving to the next of the configured base
+	// API endpoints after each call, in order to try to distribute the load evenly across the available endpoints;
+	// healthy and fast endpoints are preferred over the failing or slow ones.
 
-		if options.Context == nil {
-			request, err = http.NewRequest(options.Method, finalUrl, options.Body)
-		} else {
-			request, err = http.NewRequestWithContext(options.Context, options.Method, finalUrl, options.Body)
-		}
+	tried := make([]bool, len(client.baseUrls))
+
+	for attempt := 1; ; attempt++ {
+		// Retrieve the API base URL; the next request will be performed on a subsequent API endpoint
+
+		offset := atomic.AddUint64(&client.currentBaseUrlIdx, 1) - 1
+		endpointIdx := client.endpoints.acquire(offset, tried)
+		baseUrl := client.baseUrls[endpointIdx]
+		tried[endpointIdx] = true
+
+		var roundTrip time.Duration
+		response, invErr, err := client.invokeAuthenticated(baseUrl, options, getBody, &roundTrip)
+		client.endpoints.report(endpointIdx, latencyOf(options, roundTrip), endpointOutcomeOf(options, response, invErr))
 
 		if err != nil {
-			errs = append(errs, invocationError{
-				url:   finalUrl,
-				error: err,
-			})
+			return nil, err
+		}
 
-			continue
+		// Once the caller gives up (or the operation times out), the failure says nothing about the endpoints
+
+		if invErr != nil && options.Context != nil && options.Context.Err() != nil {
+			return nil, options.Context.Err()
 		}
 
-		// Default headers
+		if invErr == nil && !isTransientFailure(response) {
+			return response, nil
+		}
 
-		request.Header.Set("User-Agent", client.userAgent)
-		request.Header.Set("Content-Type", ContentType.ApplicationJson)
-		request.Header.Set("Accept", ContentType.ApplicationJson)
+		// Ask the retry policy whether to perform another attempt
 
-		// Custom headers
+		retryAttempt := RetryAttempt{
+			Number:        attempt,
+			Method:        options.Method,
+			Response:      response,
+			NoOfEndpoints: len(client.baseUrls),
+		}
+
+		if invErr != nil {
+			errs = append(errs, EndpointError{
+				Url: invErr.url,
+				Err: invErr.error,
+			})
+			retryAttempt.Err = invErr.error
+			retryAttempt.RequestSent = invErr.sent
+		}
+
+		delay, retry := client.retryPolicy.ShouldRetry(retryAttempt)
 
-		if options.Headers != nil {
-			for k, v := range options.Headers {
-				request.Header.Set(k, v)
+		if retry && options.Context != nil && options.Context.Err() != nil {
+			retry = false
+		}
+
+		if !retry {
+			// Let the caller handle the eventual unsuccessful response
+
+			if response != nil {
+				return response, nil
 			}
+
+			break
 		}
 
-		// Authenticate the underlying client, if needed
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			_ = response.Body.Close()
+		}
 
-		err = client.authenticationProvider.Authenticate(request)
+		if err := sleepContext(options.Context, delay); err != nil {
+			return nil, err
+		}
+	}
 
-		if err != nil {
-			errs = append(errs, invocationError{
-				url:   finalUrl,
-				error: err,
-			})
+	// Generate an error out of the potentially multiple invocation errors
+
+	return nil, &EndpointsUnreachableError{
+		Errors: errs,
+	}
+}
+
+// invokeAuthenticated sends the request to the specified API endpoint, retrying it once if the authentication
+// provider manages to recover from an HTTP 401 status code. The last returned error is set in the event
+// the request is definitely rejected by the API, and should not be retried. The duration of the last round trip to the
+// endpoint is stored into roundTrip.
+func (client *multiplexedRestClient) invokeAuthenticated(baseUrl string, options InvocationOptions, getBody func() (io.Reader, error), roundTrip *time.Duration) (*http.Response, *invocationError, error) {
+	response, invErr := client.invokeEndpoint(baseUrl, options, getBody, roundTrip)
+
+	if err := authenticationFailureOf(invErr); err != nil {
+		return nil, nil, err
+	}
+
+	if invErr != nil || !isUnauthorized(response) {
+		return response, invErr, nil
+	}
+
+	// Give the authentication provider a chance to recover (for example, by refreshing its token) and then
+	// retry the request once against the same endpoint
+
+	apiError := NewAPIError(response)
+
+	if err := client.authenticationProvider.HandleUnauthorizedRequest(); err != nil {
+		return nil, nil, apiError
+	}
+
+	response, invErr = client.invokeEndpoint(baseUrl, options, getBody, roundTrip)
+
+	if err := authenticationFailureOf(invErr); err != nil {
+		return nil, nil, err
+	}
+
+	if invErr != nil {
+		return nil, invErr, nil
+	}
+
+	if isUnauthorized(response) {
+		return nil, nil, NewAPIError(response)
+	}
+
+	return response, nil, nil
+}
+
+// invokeEndpoint sends the request to the specified API endpoint and stores the duration of the round trip into
+// roundTrip, leaving out the authentication of the request (which may involve acquiring a token).
+func (client *multiplexedRestClient) invokeEndpoint(baseUrl string, options InvocationOptions, getBody func() (io.Reader, error), roundTrip *time.Duration) (*http.Response, *invocationError) {
+	// Set up the query string
+
+	queryString := ""
+
+	if options.QueryParams != nil && len(options.QueryParams) > 0 {
+		queryString = options.QueryParams.Encode()
+	}
+
+	// Build the final URL
+
+	finalUrl := fmt.Sprintf("%s/%s?%s", baseUrl, options.Resource, queryString)
 
-			continue
+	// log.Printf("%v %v...\n", options.Method, finalUrl)
+
+	// Init the HTTP request, with a fresh copy of the body
+
+	body, err := getBody()
+
+	if err != nil {
+		return nil, &invocationError{
+			url:   finalUrl,
+			error: err,
 		}
+	}
 
-		// Send the request to the Verifalia servers
+	var request *http.Request
 
-		response, err := client.underlyingClient.Do(request)
+	if options.Context == nil {
+		request, err = http.NewRequest(options.Method, finalUrl, body)
+	} else {
+		request, err = http.NewRequestWithContext(options.Context, options.Method, finalUrl, body)
+	}
 
-		if err != nil {
-			errs = append(errs, invocationError{
-				url:   finalUrl,
-				error: err,
-			})
+	if err != nil {
+		return nil, &invocationError{
+			url:   finalUrl,
+			error: err,
+		}
+	}
+
+	if sectionReader, ok := body.(*io.SectionReader); ok {
+		request.ContentLength = sectionReader.Size()
+	}
 
-			continue
+	// Default headers
+
+	request.Header.Set("User-Agent", client.userAgent)
+	request.Header.Set("Content-Type", ContentType.ApplicationJson)
+	request.Header.Set("Accept", ContentType.ApplicationJson)
+
+	// Custom headers
+
+	if options.Headers != nil {
+		for k, v := range options.Headers {
+			request.Header.Set(k, v)
 		}
+	}
+
+	// Authenticate the underlying client, if needed
+
+	err = client.authenticationProvider.Authenticate(request)
 
-		// Fails on the first occurrence of an HTTP { 401, 403 } status codes
+	// A response the provider got from the API while authenticating the request is handled as the one of the request
 
-		if response.StatusCode == 401 || response.StatusCode == 403 {
-			return nil, fmt.Errorf("can't authenticate to Verifalia using the provided credential (HTTP status code: %d)", response.StatusCode)
+	var responseErr *auth.ResponseError
+
+	if errors.As(err, &responseErr) {
+		return responseErr.Response(), nil
+	}
+
+	if err != nil {
+		return nil, &invocationError{
+			url:   finalUrl,
+			error: err,
 		}
+	}
+
+	// Send the request to the Verifalia servers, keeping track of whether a connection has been established: up to
+	// that point, the request can't have reached the API
 
-		return response, nil
+	var connected int32
+
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			atomic.StoreInt32(&connected, 1)
+		},
+	}))
+
+	// The API deliberately holds the requests with a waitTime: give them that much time on top of the usual timeout
+
+	httpClient := client.underlyingClient
+	timeout := httpClient.Timeout
+
+	if options.Timeout != 0 {
+		timeout = max(options.Timeout, 0)
 	}
 
-	// Generate an error out of the potentially multiple invocation errors
+	if waitTime := waitTimeOf(options); waitTime > 0 && timeout > 0 {
+		timeout += waitTime
+	}
+
+	if timeout != httpClient.Timeout {
+		customClient := *httpClient
+		customClient.Timeout = timeout
+		httpClient = &customClient
+	}
+
+	startedOn := time.Now()
+	response, err := httpClient.Do(request)
+	*roundTrip = time.Since(startedOn)
+
+	if err != nil {
+		return nil, &invocationError{
+			url:       finalUrl,
+			error:     err,
+			transport: true,
+			sent:      atomic.LoadInt32(&connected) == 1,
+		}
+	}
+
+	return response, nil
+}
+
+// EndpointHealth returns a snapshot of the health of the API endpoints, in the order of the configured base URLs.
+func (client *multiplexedRestClient) EndpointHealth() []EndpointHealth {
+	return client.endpoints.snapshot()
+}
+
+// latencyOf returns the round trip time of the specified request, or zero if it says nothing about the latency of the
+// endpoint: requests with a waitTime are deliberately held by the API until the job completes or the time elapses.
+func latencyOf(options InvocationOptions, roundTrip time.Duration) time.Duration {
+	if waitTimeOf(options) > 0 {
+		return 0
+	}
+
+	return roundTrip
+}
+
+// waitTimeOf returns the time the API is asked to hold the specified request, through its waitTime parameter.
+func waitTimeOf(options InvocationOptions) time.Duration {
+	waitTime, err := strconv.ParseFloat(options.QueryParams.Get("waitTime"), 64)
+
+	if err != nil || waitTime <= 0 {
+		return 0
+	}
+
+	return time.Duration(waitTime * float64(time.Second))
+}
+
+func endpointOutcomeOf(options InvocationOptions, response *http.Response, invErr *invocationError) endpointOutcome {
+	if options.Context != nil && options.Context.Err() != nil {
+		return endpointUndetermined
+	}
+
+	if invErr != nil {
+		if invErr.transport {
+			return endpointFailed
+		}
+
+		return endpointUndetermined
+	}
+
+	if response != nil && response.StatusCode >= 500 {
+		return endpointFailed
+	}
+
+	return endpointSucceeded
+}
+
+// cancelOnCloseBody releases the context of an operation once its response body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnCloseBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// authenticationFailureOf returns the error of the authentication provider, if it could not authenticate the request
+// because its credentials have been rejected: trying again, or against another endpoint, would not help.
+func authenticationFailureOf(invErr *invocationError) error {
+	if invErr != nil && errors.Is(invErr.error, ErrAuthenticationFailed) {
+		return invErr.error
+	}
+
+	return nil
+}
+
+// isUnauthorized returns true if the API could not authenticate the request; requests which are authenticated but
+// not authorized (HTTP status code 403) are not, as a new token or credential would be rejected all the same.
+func isUnauthorized(response *http.Response) bool {
+	return response.StatusCode == http.StatusUnauthorized
+}
+
+// newBodyFactory returns a function which provides, for each attempt, a new reader over the whole request body.
+func newBodyFactory(options InvocationOptions) (func() (io.Reader, error), error) {
+	if options.GetBody != nil {
+		return options.GetBody, nil
+	}
+
+	if options.Body == nil {
+		return func() (io.Reader, error) {
+			return nil, nil
+		}, nil
+	}
+
+	// Random access readers are replayed through independent section readers, which do not share any offset
+
+	if readerAt, ok := options.Body.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		offset, err := readerAt.Seek(0, io.SeekCurrent)
+
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := readerAt.Seek(0, io.SeekEnd)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := readerAt.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		return func() (io.Reader, error) {
+			return io.NewSectionReader(readerAt, offset, size-offset), nil
+		}, nil
+	}
+
+	// Any other reader can be consumed only once, so its data is buffered
 
-	finalErrorMessage := "All the base URIs are unreachable."
+	data, err := io.ReadAll(options.Body)
 
-	for _, err := range errs {
-		finalErrorMessage = fmt.Sprintf("%v\n%v => %v", finalErrorMessage, err.url, err.error)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New(finalErrorMessage)
+	return func() (io.Reader, error) {
+		return bytes.NewReader(data), nil
+	}, nil
 }
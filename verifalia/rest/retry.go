@@ -0,0 +1,226 @@
+package rest
+
+/*
+* Verifalia - Email list cleaning and real-time email verification service
+* https://verifalia.com/
+* support@verifalia.com
+*
+* Copyright (c) 2005-2024 Cobisi Research
+*
+* Cobisi Research
+* Via Della Costituzione, 31
+* 35010 Vigonza
+* Italy - European Union
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in
+* all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+* THE SOFTWARE.
+ */
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryAttempt describes a failed attempt to invoke the Verifalia API.
+type RetryAttempt struct {
+	// The 1-based number of the failed attempt.
+	Number int
+
+	// The HTTP method of the request.
+	Method string
+
+	// The response returned by the Verifalia API, or nil in the event of a transport error.
+	Response *http.Response
+
+	// The transport error, if the request could not be completed.
+	Err error
+
+	// True if the transport error occurred after connecting to the API endpoint, thus the request may have reached
+	// the Verifalia API; false for the errors which occurred while resolving, dialing or handshaking with the endpoint.
+	RequestSent bool
+
+	// The number of API endpoints configured in the REST client.
+	NoOfEndpoints int
+}
+
+// RetryPolicy decides whether a failed attempt to invoke the Verifalia API should be retried and how long to wait
+// before doing so. Retries are performed against the next available API endpoint. Failed attempts include transport
+// errors as well as responses with the HTTP status code 429 or 5xx.
+type RetryPolicy interface {
+	// ShouldRetry returns true if the failed attempt should be retried, along with the delay to observe before the
+	// next attempt.
+	ShouldRetry(attempt RetryAttempt) (delay time.Duration, retry bool)
+}
+
+// ExponentialBackoffRetryPolicy is a RetryPolicy which retries transport errors immediately (thus failing over to the
+// next API endpoint) and the HTTP status codes 429, 500, 502, 503 and 504 with an exponential backoff with jitter,
+// honouring the Retry-After header sent by the Verifalia API, if any.
+// Since the Verifalia API may have already processed a non-idempotent request (such as the POST used to submit a
+// new email validation job) which failed after reaching the endpoint, or with a 500, 502 or 504 status code, these
+// requests are retried only upon the transport errors which occurred before connecting to the endpoint (see
+// RetryAttempt.RequestSent) and the HTTP status codes 429 and 503, unless RetryNonIdempotent is true.
+type ExponentialBackoffRetryPolicy struct {
+	// The maximum number of attempts, including the first one; zero means one attempt for each API endpoint configured
+	// in the REST client.
+	MaxAttempts int
+
+	// The delay before the first retry; each subsequent retry doubles it.
+	InitialDelay time.Duration
+
+	// The maximum delay between two attempts. Responses with a Retry-After header which exceeds this value are
+	// not retried.
+	MaxDelay time.Duration
+
+	// If true, non-idempotent requests are retried upon any transport error and retryable HTTP status code.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by the REST client unless otherwise specified.
+var DefaultRetryPolicy RetryPolicy = &ExponentialBackoffRetryPolicy{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+}
+
+var jitterMutex sync.Mutex
+var jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+func (policy *ExponentialBackoffRetryPolicy) ShouldRetry(attempt RetryAttempt) (time.Duration, bool) {
+	maxAttempts := policy.MaxAttempts
+
+	if maxAttempts <= 0 {
+		maxAttempts = attempt.NoOfEndpoints
+	}
+
+	if attempt.Number >= maxAttempts {
+		return 0, false
+	}
+
+	// Transport errors are retried right away, against the next endpoint
+
+	if attempt.Response == nil {
+		if attempt.RequestSent && !policy.RetryNonIdempotent && !isIdempotent(attempt.Method) {
+			return 0, false
+		}
+
+		return 0, true
+	}
+
+	switch attempt.Response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		if !policy.RetryNonIdempotent && !isIdempotent(attempt.Method) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	// The Retry-After header, if any, takes precedence over the backoff
+
+	if retryAfter, ok := parseRetryAfter(attempt.Response.Header.Get("Retry-After")); ok {
+		if policy.MaxDelay > 0 && retryAfter > policy.MaxDelay {
+			return 0, false
+		}
+
+		return retryAfter, true
+	}
+
+	// Exponential backoff with equal jitter: the delay is a random value between half and the whole backoff
+
+	backoff := policy.InitialDelay << (attempt.Number - 1)
+
+	if backoff <= 0 || (policy.MaxDelay > 0 && backoff > policy.MaxDelay) {
+		backoff = policy.MaxDelay
+	}
+
+	if backoff <= 0 {
+		return 0, true
+	}
+
+	jitterMutex.Lock()
+	jitter := time.Duration(jitterRand.Int63n(int64(backoff/2) + 1))
+	jitterMutex.Unlock()
+
+	return backoff/2 + jitter, true
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which can be either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+
+		if delay < 0 {
+			delay = 0
+		}
+
+		return delay, true
+	}
+
+	return 0, false
+}
+
+func isTransientFailure(response *http.Response) bool {
+	return response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+}
+
+// sleepContext pauses the execution for the specified delay, or until the context is cancelled.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	if ctx == nil {
+		time.Sleep(delay)
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
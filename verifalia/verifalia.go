@@ -44,6 +44,7 @@ import (
 // Client represents a REST client for Verifalia. To start verifying email addresses, use one of the functions available
 // through the EmailValidation field, for example:
 //  validation, err := client.EmailValidation.Run("batman@gmail.com")
+// A Client is safe for concurrent use by multiple goroutines and should be reused, instead of being created as needed.
 type Client struct {
 	authenticationProvider auth.Provider
 	restClient             rest.Client
@@ -61,7 +62,7 @@ type Client struct {
 // to create one or more users with just the required permissions, for improved
 // security. To create a new user or manage existing ones, please visit https://verifalia.com/client-area#/users
 func NewClient(username string, password string) *Client {
-	return newClientImpl(auth.NewBasicAuthProvider(username, password), rest.BaseUrls)
+	return newClientImpl(auth.NewBasicAuthProvider(username, password))
 }
 
 // NewClientWithCertificateAuth initializes a new REST client for Verifalia with the specified client certificate
@@ -69,14 +70,64 @@ func NewClient(username string, password string) *Client {
 // It is strongly advised to create one or more users with just the required permissions,
 // for improved security. To create a new user or manage existing ones, please visit https://verifalia.com/client-area#/users
 func NewClientWithCertificateAuth(certificate *tls.Certificate) *Client {
-	return newClientImpl(auth.NewCertificateAuthProvider(certificate), rest.BaseCcaUrls)
+	return newClientImpl(auth.NewCertificateAuthProvider(certificate))
 }
 
-func newClientImpl(authenticationProvider auth.Provider, baseUrls []string) *Client {
-	client := rest.NewMultiplexedRestClient(authenticationProvider,
-		// TODO: Add the git hash of the current SDK version to the user agent string
-		fmt.Sprintf("verifalia-rest-client/go/%s/%s", runtime.Version(), runtime.GOOS),
-		baseUrls)
+// NewClientWithBearerAuth initializes a new REST client for Verifalia which exchanges the specified username and password
+// for a bearer token (JWT), thus avoiding to send the actual credentials along with every request; the token is
+// automatically refreshed before it expires. Should the user have multi-factor authentication enabled, the specified
+// totpTokenProvider is invoked to obtain the TOTP code needed to complete the authentication; it can be nil otherwise.
+// It is strongly advised to create one or more users with just the required permissions, for improved
+// security. To create a new user or manage existing ones, please visit https://verifalia.com/client-area#/users
+func NewClientWithBearerAuth(username string, password string, totpTokenProvider auth.TotpTokenProvider) *Client {
+	return newClientImpl(auth.NewBearerAuthProvider(username, password, rest.BaseUrls, totpTokenProvider))
+}
+
+// NewClientWithOptions initializes a new REST client for Verifalia which authenticates through the specified provider
+// (see the auth package) and is configured through the specified options, for example:
+//  client, err := verifalia.NewClientWithOptions(auth.NewBasicAuthProvider("username", "password"),
+//      verifalia.WithTimeout(10*time.Second),
+//      verifalia.WithUserAgentSuffix("acme-crm/1.2"))
+// Unless otherwise specified through WithBaseUrls, the client uses the standard Verifalia API endpoints, or the
+// client-certificate authentication ones when authenticating through a client certificate.
+func NewClientWithOptions(authenticationProvider auth.Provider, options ...Option) (*Client, error) {
+	settings := clientSettings{}
+
+	for _, option := range options {
+		option(&settings)
+	}
+
+	baseUrls := settings.baseUrls
+
+	if len(baseUrls) == 0 {
+		baseUrls = rest.BaseUrls
+
+		if auth.IsCertificateAuthProvider(authenticationProvider) {
+			baseUrls = rest.BaseCcaUrls
+		}
+	}
+
+	httpClient, err := settings.buildHttpClient(authenticationProvider.BuildClient())
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The requests sent by the provider itself (for example, to acquire a bearer token) honour the same settings
+
+	if configurable, ok := authenticationProvider.(auth.ClientConfigurable); ok {
+		configurable.ConfigureClient(httpClient, settings.baseUrls)
+	}
+
+	// TODO: Add the git hash of the current SDK version to the user agent string
+	userAgent := fmt.Sprintf("verifalia-rest-client/go/%s/%s", runtime.Version(), runtime.GOOS)
+
+	if settings.userAgentSuffix != "" {
+		userAgent = fmt.Sprintf("%s %s", userAgent, settings.userAgentSuffix)
+	}
+
+	restOptions := append([]rest.ClientOption{rest.WithHTTPClient(httpClient)}, settings.restOptions...)
+	client := rest.NewMultiplexedRestClient(authenticationProvider, userAgent, baseUrls, restOptions...)
 
 	return &Client{
 		authenticationProvider: authenticationProvider,
@@ -87,5 +138,21 @@ func newClientImpl(authenticationProvider auth.Provider, baseUrls []string) *Cli
 		EmailValidation: emailValidation.Client{
 			RestClient: client,
 		},
+	}, nil
+}
+
+// EndpointHealth returns a snapshot of the health of the Verifalia API endpoints used by this client, including the
+// state of their circuit breakers and their average response times.
+func (client *Client) EndpointHealth() []rest.EndpointHealth {
+	if reporter, ok := client.restClient.(rest.HealthReporter); ok {
+		return reporter.EndpointHealth()
 	}
+
+	return nil
+}
+
+func newClientImpl(authenticationProvider auth.Provider) *Client {
+	// Can't fail, as there are no options to apply
+	client, _ := NewClientWithOptions(authenticationProvider)
+	return client
 }